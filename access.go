@@ -0,0 +1,102 @@
+package libsmb2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AccessMask is an SMB2 access mask as requested in CREATE.
+type AccessMask uint32
+
+const (
+	FileReadData        AccessMask = 0x00000001
+	FileListDirectory   AccessMask = 0x00000001
+	FileWriteData       AccessMask = 0x00000002
+	FileAddFile         AccessMask = 0x00000002
+	FileAppendData      AccessMask = 0x00000004
+	FileAddSubdirectory AccessMask = 0x00000004
+	FileReadEA          AccessMask = 0x00000008
+	FileWriteEA         AccessMask = 0x00000010
+	FileExecute         AccessMask = 0x00000020
+	FileDeleteChild     AccessMask = 0x00000040
+	FileReadAttributes  AccessMask = 0x00000080
+	FileWriteAttributes AccessMask = 0x00000100
+	Delete              AccessMask = 0x00010000
+	ReadControl         AccessMask = 0x00020000
+	WriteDAC            AccessMask = 0x00040000
+	WriteOwner          AccessMask = 0x00080000
+	Synchronize         AccessMask = 0x00100000
+)
+
+var accessNames = []struct {
+	mask AccessMask
+	name string
+}{
+	{FileReadData, "READ_DATA"},
+	{FileWriteData, "WRITE_DATA"},
+	{FileAppendData, "APPEND_DATA"},
+	{FileReadEA, "READ_EA"},
+	{FileWriteEA, "WRITE_EA"},
+	{FileExecute, "EXECUTE"},
+	{FileDeleteChild, "DELETE_CHILD"},
+	{FileReadAttributes, "READ_ATTRIBUTES"},
+	{FileWriteAttributes, "WRITE_ATTRIBUTES"},
+	{Delete, "DELETE"},
+	{ReadControl, "READ_CONTROL"},
+	{WriteDAC, "WRITE_DAC"},
+	{WriteOwner, "WRITE_OWNER"},
+	{Synchronize, "SYNCHRONIZE"},
+}
+
+func (m AccessMask) String() string {
+	var names []string
+	rest := m
+	for _, a := range accessNames {
+		if m&a.mask != 0 {
+			names = append(names, a.name)
+			rest &^= a.mask
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("0x%08x", uint32(rest)))
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// opAccess is the access each path operation needs on its target.
+var opAccess = map[string]AccessMask{
+	"stat":     FileReadAttributes,
+	"readdir":  FileListDirectory | FileReadAttributes,
+	"readlink": FileReadAttributes | FileReadData,
+	"mkdir":    FileAddSubdirectory,
+	"rmdir":    Delete,
+	"unlink":   Delete,
+	"rename":   Delete,
+}
+
+// accessForFlags returns the access an open with POSIX flags asks for.
+func accessForFlags(flags int) AccessMask {
+	read := FileReadData | FileReadEA | FileReadAttributes | ReadControl | Synchronize
+	write := FileWriteData | FileAppendData | FileWriteEA | FileWriteAttributes | ReadControl | Synchronize
+	switch flags & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_WRONLY:
+		return write
+	case os.O_RDWR:
+		return read | write
+	}
+	return read
+}
+
+// withAccess records the requested access on err if the server denied it.
+func withAccess(err error, access AccessMask) error {
+	var e *Error
+	if access != 0 && errors.As(err, &e) && errors.Is(e.Err, os.ErrPermission) {
+		e.Access = access
+	}
+	return err
+}
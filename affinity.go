@@ -0,0 +1,79 @@
+package libsmb2
+
+import (
+	"sync"
+)
+
+// Affinity pins the sessions that share it to one node of a server name
+// that resolves to several, as behind a scale-out file server or a
+// round-robin DNS name, so that reconnects land where the earlier state
+// is. Create one per logical server and hand it to every session with
+// SetAffinity.
+type Affinity struct {
+	// Reresolve looks the name up again on every connect. The pinned
+	// node is kept while it is still among the answers; otherwise the
+	// first answer becomes the new pin. Without Reresolve the pin holds
+	// until Unpin.
+	Reresolve bool
+
+	mutex sync.Mutex
+	host  string
+	addr  string
+}
+
+// Address returns the pinned address, "" before the first connect.
+func (a *Affinity) Address() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.addr
+}
+
+// Unpin forgets the pinned node, so the next connect resolves afresh.
+func (a *Affinity) Unpin() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.addr = ""
+}
+
+// resolve returns the address to connect to for host, looking it up with
+// lookup.
+func (a *Affinity) resolve(host string, lookup func(string) ([]string, error)) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.host != host {
+		a.host, a.addr = host, ""
+	}
+	if a.addr != "" && !a.Reresolve {
+		return a.addr, nil
+	}
+	addrs, err := lookup(host)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if addr == a.addr {
+			return addr, nil
+		}
+	}
+	a.addr = addrs[0]
+	return a.addr, nil
+}
+
+// SetAffinity makes Connect go to the node pinned by a instead of letting
+// libsmb2 resolve the name. The server is then reached by address, which
+// suits NTLM; Kerberos needs the name for its service ticket, so leave
+// affinity off for Kerberos sessions.
+func (s *Smb) SetAffinity(a *Affinity) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.affinity = a
+}
+
+// RemoteAddr returns the address the session connected to: the pinned
+// node with affinity, the resolved address with SetResolver, the host as
+// given otherwise.
+func (s *Smb) RemoteAddr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.remoteAddr
+}
@@ -0,0 +1,97 @@
+package libsmb2
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Adaptive limits the calls in flight across the sessions it is installed
+// on, adjusting the limit the way TCP adjusts its window: it grows by one
+// for every limit's worth of calls that come back healthy, and halves
+// when a call is slower than the target latency or the server reports
+// STATUS_INSUFF_SERVER_RESOURCES. A session runs one call at a time, so
+// the limit matters across sessions, such as the Sources and
+// Destinations of a CopyTree.
+type Adaptive struct {
+	min, max int
+	target   time.Duration
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	limit    float64
+	inflight int
+	backoff  time.Time
+}
+
+// NewAdaptive returns an Adaptive starting at min calls in flight and
+// never leaving [min, max]. Calls slower than target count as congestion;
+// a zero target only reacts to the server's resource errors.
+func NewAdaptive(min int, max int, target time.Duration) *Adaptive {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	a := &Adaptive{min: min, max: max, target: target, limit: float64(min)}
+	a.cond = sync.NewCond(&a.mutex)
+	return a
+}
+
+// Limit returns the current number of calls allowed in flight.
+func (a *Adaptive) Limit() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return int(a.limit)
+}
+
+// Middleware returns the middleware that applies the limit. Close is not
+// held back, so that waiting calls cannot keep handles from being freed.
+func (a *Adaptive) Middleware() Middleware {
+	return func(next Invoker) Invoker {
+		return func(c *Call) error {
+			if c.Op == "close" {
+				return next(c)
+			}
+			a.acquire()
+			start := time.Now()
+			err := next(c)
+			a.release(time.Since(start), err)
+			return err
+		}
+	}
+}
+
+func (a *Adaptive) acquire() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for a.inflight >= int(a.limit) {
+		a.cond.Wait()
+	}
+	a.inflight++
+}
+
+func (a *Adaptive) release(d time.Duration, err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.inflight--
+	now := time.Now()
+	if errors.Is(err, StatusInsuffServerResources) || (a.target > 0 && d > a.target) {
+		// Halve once per congestion event: calls that were already in
+		// flight when it began would otherwise cut the limit again.
+		if now.After(a.backoff) {
+			a.limit /= 2
+			if a.limit < float64(a.min) {
+				a.limit = float64(a.min)
+			}
+			a.backoff = now.Add(d)
+		}
+	} else if err == nil {
+		a.limit += 1 / a.limit
+		if a.limit > float64(a.max) {
+			a.limit = float64(a.max)
+		}
+	}
+	a.cond.Broadcast()
+}
@@ -0,0 +1,64 @@
+package libsmb2
+
+import (
+	"time"
+)
+
+// SecurityReport is the security posture of a connection, for scanners
+// that inventory weak servers. libsmb2 does not expose the negotiated
+// dialect, signing state or the guest flag of the session setup, so those
+// are only known as far as the session required them: a strict session
+// that connected is known to run SMB 3 with signing, any other reports
+// them as unknown. Encryption is known whenever it was asked for, because
+// libsmb2 fails the connect of a sealed session the server cannot encrypt.
+type SecurityReport struct {
+	Addr      string    `json:"addr"`
+	Connected time.Time `json:"connected"`
+	// Auth is "ntlmssp" or "krb5".
+	Auth string `json:"auth"`
+	// Dialect is "3.x" or "unknown".
+	Dialect string `json:"dialect"`
+	// Signing is "required" or "unknown".
+	Signing string `json:"signing"`
+	// Encryption is "on" or "unknown".
+	Encryption string `json:"encryption"`
+	// Guest is "no" or "unknown".
+	Guest string `json:"guest"`
+}
+
+// SecurityReport returns the report of the last successful Connect, nil
+// before one.
+func (s *Smb) SecurityReport() *SecurityReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.security == nil {
+		return nil
+	}
+	r := *s.security
+	return &r
+}
+
+// recordSecurity fills in the report of a connect that just succeeded.
+// Must be called with s.mutex held.
+func (s *Smb) recordSecurity() {
+	r := &SecurityReport{
+		Addr:       s.remoteAddr,
+		Connected:  time.Now(),
+		Auth:       "ntlmssp",
+		Dialect:    "unknown",
+		Signing:    "unknown",
+		Encryption: "unknown",
+		Guest:      "unknown",
+	}
+	if s.kerberos {
+		// Kerberos has no guest fallback.
+		r.Auth, r.Guest = "krb5", "no"
+	}
+	if s.strict {
+		r.Dialect, r.Signing, r.Guest = "3.x", "required", "no"
+	}
+	if s.seal || s.strict {
+		r.Encryption = "on"
+	}
+	s.security = r
+}
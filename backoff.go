@@ -0,0 +1,152 @@
+package libsmb2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//#include "libsmb2go.h"
+import "C"
+
+// BackoffOptions makes Connect retry, see SetConnectBackoff.
+type BackoffOptions struct {
+	// Attempts is the total number of tries, default 5.
+	Attempts int
+	// Initial is the delay before the second try, default one second;
+	// each later delay doubles, up to Max.
+	Initial time.Duration
+	// Max caps the delay, default 30 seconds.
+	Max time.Duration
+	// Retry decides whether a failed try is worth repeating, by default
+	// anything but a logon failure, which would also risk locking the
+	// account out, and a *StrictError, which the same server gives again.
+	// A session that was disconnected or is shutting down fails with
+	// ErrClosed and is never retried.
+	Retry func(err error) bool
+	// OnAttempt is told about every try once it has failed or succeeded.
+	OnAttempt func(ConnectEvent)
+}
+
+// ConnectEvent describes one try of a retrying Connect. Delay is how long
+// Connect waits before the next try, 0 after the last one.
+type ConnectEvent struct {
+	Attempt int
+	Addr    string
+	Err     error
+	Delay   time.Duration
+}
+
+// ConnectError is returned by a retrying Connect that gave up. It lists
+// every try and unwraps to their errors.
+type ConnectError struct {
+	Attempts []ConnectEvent
+}
+
+func (e *ConnectError) Error() string {
+	parts := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		parts[i] = fmt.Sprintf("attempt %d (%s): %v", a.Attempt, a.Addr, a.Err)
+	}
+	return "connect failed after " + fmt.Sprint(len(e.Attempts)) + " attempts: " + strings.Join(parts, "; ")
+}
+
+func (e *ConnectError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a.Err
+	}
+	return errs
+}
+
+// SetConnectBackoff makes Connect retry failed connects with exponential
+// backoff; nil restores the single try. libsmb2 cannot reuse a context
+// whose connect failed, so each retry starts from a new one with the
+// seal, Kerberos, strict and timeout settings applied again.
+func (s *Smb) SetConnectBackoff(opts *BackoffOptions) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.backoff = opts
+}
+
+// connectBackoff is Connect with retries as configured by opts. Only
+// contexts destroyed by its own failed tries are replaced.
+func (s *Smb) connectBackoff(host string, share string, user string, password string, opts *BackoffOptions) error {
+	attempts, delay, max, retry := opts.Attempts, opts.Initial, opts.Max, opts.Retry
+	if attempts <= 0 {
+		attempts = 5
+	}
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	if retry == nil {
+		retry = func(err error) bool {
+			var strict *StrictError
+			return !errors.Is(err, StatusLogonFailure) && !errors.As(err, &strict)
+		}
+	}
+	var tries []ConnectEvent
+	for i := 1; ; i++ {
+		if i > 1 {
+			s.mutex.Lock()
+			s.reinit()
+			s.mutex.Unlock()
+		}
+		err := s.invoke("connect", host+"/"+share, 0, func() error {
+			return s.connect(host, share, user, password)
+		})
+		s.mutex.Lock()
+		ev := ConnectEvent{Attempt: i, Addr: s.triedAddr, Err: err}
+		s.mutex.Unlock()
+		if ev.Addr == "" {
+			ev.Addr = host
+		}
+		// ErrClosed means a session disconnected or shutting down, which
+		// a retry must not bring back.
+		last := err == nil || i >= attempts || errors.Is(err, ErrClosed) || !retry(err)
+		if !last {
+			ev.Delay = delay
+		}
+		tries = append(tries, ev)
+		if opts.OnAttempt != nil {
+			opts.OnAttempt(ev)
+		}
+		if err == nil {
+			return nil
+		}
+		if last {
+			return &ConnectError{Attempts: tries}
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
+
+// reinit replaces a context destroyed by a failed connect with a new one
+// set up like it. Must be called with s.mutex held.
+func (s *Smb) reinit() {
+	if s.session != nil {
+		return
+	}
+	s.session = C.smb2_init_context()
+	s.connected = false
+	if s.seal || s.strict {
+		C.libsmb2go_set_seal(s.session, 1)
+	}
+	if s.kerberos {
+		C.libsmb2go_set_authentication(s.session, 1)
+	}
+	if s.strict {
+		C.smb2_set_version(s.session, C.SMB2_VERSION_ANY3)
+		C.smb2_set_security_mode(s.session, C.SMB2_NEGOTIATE_SIGNING_ENABLED|C.SMB2_NEGOTIATE_SIGNING_REQUIRED)
+	}
+	if s.timeout > 0 {
+		C.smb2_set_timeout(s.session, C.int(timeoutSeconds(s.timeout)))
+	}
+}
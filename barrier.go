@@ -0,0 +1,78 @@
+package libsmb2
+
+import (
+	"errors"
+	"time"
+)
+
+//#include "libsmb2go.h"
+import "C"
+
+// Sync asks the server to commit the data written to f to stable storage.
+func (f *smbFile) Sync() error {
+	return f.smb.invoke("fsync", f.path, 0, func() error {
+		f.smb.mutex.Lock()
+		defer f.smb.mutex.Unlock()
+		return f.sync()
+	})
+}
+
+// sync flushes f if it has an open handle. Must be called with
+// f.smb.mutex held.
+func (f *smbFile) sync() error {
+	if f.fd == nil || f.smb.session == nil {
+		if _, open := f.smb.files[f]; f.reaped || !open {
+			// Closing the handle, by the reaper or the owner, already
+			// pushed the data to the server.
+			return nil
+		}
+		return f.smb.wrapError("fsync", f.path, ErrClosed)
+	}
+	defer f.smb.logSlow("fsync", f.path, 0, time.Now())
+	if err := f.smb.injectFault("fsync", f.path, 0); err != nil {
+		return f.smb.wrapError("fsync", f.path, err)
+	}
+	restore, err := f.applyDeadline("fsync")
+	if err != nil {
+		return err
+	}
+	defer restore()
+	var cerr cError
+	if code := C.smb2_fsync_wrapper(f.smb.session, f.fd, cerr.ptr(), cerr.len()); code < 0 {
+		return f.smb.newError("fsync", f.path, int(code), cerr.String())
+	}
+	f.dirty = false
+	return nil
+}
+
+// Barrier flushes every open file written to since its last flush, so
+// that everything written through the session before the call is on
+// stable storage when it returns, as needed before triggering a
+// server-side snapshot. Writers of compressed or encrypted content are
+// flushed first: sealing ends the chunk in progress early, and
+// compression is flushed if the Compressor's writer has a Flush method,
+// else Barrier fails for that file with ErrNotSupported. Files that are
+// only read are skipped. The returned error joins those of the files that
+// failed.
+func (s *Smb) Barrier() error {
+	var errs []error
+	for _, w := range s.writersOpen() {
+		if err := w.flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.mutex.Lock()
+	var dirty []*smbFile
+	for f := range s.files {
+		if f.dirty {
+			dirty = append(dirty, f)
+		}
+	}
+	s.mutex.Unlock()
+	for _, f := range dirty {
+		if err := f.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
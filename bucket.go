@@ -0,0 +1,345 @@
+package libsmb2
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	path2 "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBadKey is returned by Bucket for keys that cannot name a file: empty,
+// with empty, "." or ".." components, or inside the bucket's own staging
+// directory.
+var ErrBadKey = errors.New("invalid object key")
+
+// stagingDir holds temporary objects and multipart uploads of a Bucket.
+const stagingDir = ".multipart"
+
+// Bucket maps object store semantics onto a directory of a share, to ease
+// moving code written against S3. Keys are slash-separated paths below
+// the root; writes go to a temporary file first and are renamed into
+// place, so readers never see a partial object.
+type Bucket struct {
+	smb  *Smb
+	root string
+}
+
+// ObjectInfo describes an object. ETag is that of the file, see ETag, the
+// same from Put, Head and List; unlike S3's it is not a digest of the
+// content. Part ETags, from UploadPart, are the hex MD5 of the part.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+}
+
+// Part names a finished part of a multipart upload.
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// NewBucket returns a Bucket rooted at root on s.
+func NewBucket(s *Smb, root string) *Bucket {
+	return &Bucket{smb: s, root: root}
+}
+
+func (b *Bucket) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrBadKey
+	}
+	for i, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || (i == 0 && part == stagingDir) {
+			return "", ErrBadKey
+		}
+	}
+	return path2.Join(b.root, key), nil
+}
+
+// Put stores the content of r under key, replacing any previous object.
+func (b *Bucket) Put(key string, r io.Reader) (*ObjectInfo, error) {
+	target, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := b.stage(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.publish(tmp, target); err != nil {
+		return nil, err
+	}
+	return b.Head(key)
+}
+
+// stage writes r to a new temporary file and returns its path.
+func (b *Bucket) stage(r io.Reader) (string, error) {
+	dir := path2.Join(b.root, stagingDir, "tmp")
+	if err := b.smb.MkdirAll(dir); err != nil {
+		return "", err
+	}
+	tmp := path2.Join(dir, randomName())
+	f, err := b.smb.open(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", err
+	}
+	w, err := b.smb.contentWriter(f)
+	if err != nil {
+		removeCopied(b.smb, tmp)
+		return "", err
+	}
+	if err := writeAndClose(w, r); err != nil {
+		removeCopied(b.smb, tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// publish renames tmp to target, replacing target. libsmb2 cannot rename
+// over an existing file, so the old object is removed first and readers
+// may see it missing for that moment.
+func (b *Bucket) publish(tmp string, target string) error {
+	if err := b.smb.MkdirAll(path2.Dir(target)); err != nil {
+		removeCopied(b.smb, tmp)
+		return err
+	}
+	if err := removeCopied(b.smb, target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeCopied(b.smb, tmp)
+		return err
+	}
+	if err := b.smb.Rename(tmp, target); err != nil {
+		removeCopied(b.smb, tmp)
+		return err
+	}
+	return nil
+}
+
+func randomName() string {
+	var raw [16]byte
+	rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
+
+// Get opens the object under key.
+func (b *Bucket) Get(key string) (io.ReadCloser, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.smb.open(p, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDir() {
+		f.Close()
+		return nil, b.smb.wrapError("open", p, os.ErrNotExist)
+	}
+	return b.smb.contentReader(f)
+}
+
+// Head describes the object under key.
+func (b *Bucket) Head(key string) (*ObjectInfo, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.smb.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, b.smb.wrapError("stat", p, os.ErrNotExist)
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ETag: strings.Trim(ETag(info), `"`)}, nil
+}
+
+// Delete removes the object under key. Deleting a missing object is not an
+// error, and directories left empty are kept.
+func (b *Bucket) Delete(key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := b.smb.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns up to max objects whose keys start with prefix, in key
+// order, after the position of token ("" to start). next is the token of
+// the following page, "" at the end.
+func (b *Bucket) List(prefix string, token string, max int) (objects []ObjectInfo, next string, err error) {
+	after := ""
+	if token != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return nil, "", ErrBadToken
+		}
+		after = string(raw)
+	}
+	// Only the directory the prefix points into needs walking.
+	dir := b.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = path2.Join(b.root, prefix[:i])
+	}
+	base := strings.Trim(b.root, "/")
+	var all []ObjectInfo
+	err = b.smb.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(strings.Trim(p, "/"), base), "/")
+		if info.IsDir() {
+			if key == stagingDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(key, prefix) && key > after {
+			all = append(all, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ETag: strings.Trim(ETag(info), `"`)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+	if max > 0 && len(all) > max {
+		all = all[:max]
+		next = base64.RawURLEncoding.EncodeToString([]byte(all[max-1].Key))
+	}
+	return all, next, nil
+}
+
+// CreateMultipartUpload starts an upload to key whose parts are sent
+// separately, possibly from several hosts, and returns its ID.
+func (b *Bucket) CreateMultipartUpload(key string) (string, error) {
+	if _, err := b.path(key); err != nil {
+		return "", err
+	}
+	id := randomName()
+	dir := path2.Join(b.root, stagingDir, id)
+	if err := b.smb.MkdirAll(dir); err != nil {
+		return "", err
+	}
+	if err := b.smb.WriteFile(path2.Join(dir, "key"), []byte(key)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (b *Bucket) uploadDir(id string) (string, error) {
+	if raw, err := hex.DecodeString(id); err != nil || len(raw) != 16 {
+		return "", ErrBadToken
+	}
+	return path2.Join(b.root, stagingDir, id), nil
+}
+
+func partName(n int) string {
+	return fmt.Sprintf("part-%05d", n)
+}
+
+// UploadPart stores part number n (1 to 10000) of an upload, replacing an
+// earlier attempt at the same part, and returns its ETag.
+func (b *Bucket) UploadPart(id string, n int, r io.Reader) (string, error) {
+	dir, err := b.uploadDir(id)
+	if err != nil {
+		return "", err
+	}
+	if n < 1 || n > 10000 {
+		return "", fmt.Errorf("part number %d out of range", n)
+	}
+	h := md5.New()
+	tmp, err := b.stage(io.TeeReader(r, h))
+	if err != nil {
+		return "", err
+	}
+	if err := b.publish(tmp, path2.Join(dir, partName(n))); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompleteMultipartUpload joins the listed parts, in order, into the
+// object and discards the upload. The ETags must match those UploadPart
+// returned.
+func (b *Bucket) CompleteMultipartUpload(id string, parts []Part) (*ObjectInfo, error) {
+	dir, err := b.uploadDir(id)
+	if err != nil {
+		return nil, err
+	}
+	rawKey, err := b.smb.ReadFile(path2.Join(dir, "key"))
+	if err != nil {
+		return nil, err
+	}
+	key := string(rawKey)
+	target, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		for i, part := range parts {
+			if i > 0 && part.Number <= parts[i-1].Number {
+				pw.CloseWithError(fmt.Errorf("parts out of order at %d", part.Number))
+				return
+			}
+			f, err := b.smb.open(path2.Join(dir, partName(part.Number)), os.O_RDONLY)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			r, err := b.smb.contentReader(f)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			h := md5.New()
+			_, err = io.Copy(io.MultiWriter(pw, h), r)
+			r.Close()
+			if err == nil && hex.EncodeToString(h.Sum(nil)) != part.ETag {
+				err = fmt.Errorf("part %d: %w", part.Number, ErrVerifyFailed)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	tmp, err := b.stage(pr)
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := b.publish(tmp, target); err != nil {
+		return nil, err
+	}
+	b.AbortMultipartUpload(id)
+	return b.Head(key)
+}
+
+// AbortMultipartUpload discards an upload and its parts.
+func (b *Bucket) AbortMultipartUpload(id string) error {
+	dir, err := b.uploadDir(id)
+	if err != nil {
+		return err
+	}
+	b.smb.mutex.Lock()
+	defer b.smb.mutex.Unlock()
+	return b.smb.removeAll(dir)
+}
@@ -0,0 +1,266 @@
+package libsmb2
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	path2 "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultMaxConcurrentFiles = 4
+	defaultMaxInFlightBytes   = 64 << 20
+)
+
+// BulkOptions tunes the bulk helpers. The zero value uses the defaults.
+type BulkOptions struct {
+	// MaxConcurrentFiles bounds the files transferred at once, default 4.
+	MaxConcurrentFiles int
+	// MaxInFlightBytes bounds the buffer memory of all running transfers
+	// together, default 64 MiB. A transfer waits until its buffers fit.
+	MaxInFlightBytes int64
+	// Filter selects the entries to transfer, nil selects everything.
+	Filter *Filter
+	// KeepGoing records failed files, and directories that could not be
+	// listed or created, in the report and carries on with the rest
+	// instead of stopping at the first.
+	KeepGoing bool
+	// Copy is applied to every file, including its BandwidthLimit.
+	Copy CopyOptions
+	// Sources and Destinations are extra sessions, already connected to
+	// the same shares as src and dst, that CopyTree stripes files across.
+	// Each session serializes its own calls, so spreading files over
+	// several connections works around per-connection throughput limits
+	// where the server offers no multichannel.
+	Sources      []*Smb
+	Destinations []*Smb
+	// Connections is a number of extra sessions CopyTree opens itself on
+	// each side, from the descriptors of src and dst, and disconnects when
+	// it is done; they come on top of Sources and Destinations. The
+	// package does not keep credentials after Connect, so Login supplies
+	// them for the share d describes.
+	Connections int
+	Login       func(d *SessionDescriptor) (user string, password string, err error)
+}
+
+func (o *BulkOptions) concurrency() int {
+	if o.MaxConcurrentFiles > 0 {
+		return o.MaxConcurrentFiles
+	}
+	return defaultMaxConcurrentFiles
+}
+
+func (o *BulkOptions) inFlight() int64 {
+	if o.MaxInFlightBytes > 0 {
+		return o.MaxInFlightBytes
+	}
+	return defaultMaxInFlightBytes
+}
+
+// TreeReport describes what CopyTree did, by source path. Skipped lists
+// the entries the filter left out; a skipped directory stands for
+// everything below it.
+type TreeReport struct {
+	Copied  []string      `json:"copied"`
+	Skipped []string      `json:"skipped"`
+	Failed  []TreeFailure `json:"failed"`
+	Bytes   int64         `json:"bytes"`
+}
+
+// TreeFailure is a file CopyTree could not copy.
+type TreeFailure struct {
+	Path string
+	Err  error
+}
+
+func (f TreeFailure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path string `json:"path"`
+		Err  string `json:"error"`
+	}{f.Path, f.Err.Error()})
+}
+
+// CopyTree copies the tree at srcRoot on src to dstRoot on dst, creating
+// directories as needed. Several files are copied at once within the
+// limits of opts. Unless opts.KeepGoing is set, the first failure stops new
+// transfers and is returned once the running ones finish. The report is
+// returned either way and lists every file in sorted order.
+func CopyTree(src *Smb, srcRoot string, dst *Smb, dstRoot string, opts *BulkOptions) (*TreeReport, error) {
+	if opts == nil {
+		opts = &BulkOptions{}
+	}
+	copyOpts := opts.Copy
+	if copyOpts.BufferSize <= 0 {
+		copyOpts.BufferSize = int(src.Limits().MaxReadSize)
+		if copyOpts.BufferSize <= 0 {
+			copyOpts.BufferSize = defaultCopyBuffer
+		}
+	}
+	footprint := int64(copyOpts.BufferSize) * copyDepth
+	budget := newByteSemaphore(opts.inFlight())
+	slots := make(chan struct{}, opts.concurrency())
+	root := strings.Trim(srcRoot, "/")
+	srcs := append([]*Smb{src}, opts.Sources...)
+	dsts := append([]*Smb{dst}, opts.Destinations...)
+	if opts.Connections > 0 {
+		extra, err := openConnections(src, opts.Connections, opts.Login)
+		if err != nil {
+			return &TreeReport{}, err
+		}
+		defer disconnectAll(extra)
+		srcs = append(srcs, extra...)
+		if extra, err = openConnections(dst, opts.Connections, opts.Login); err != nil {
+			return &TreeReport{}, err
+		}
+		defer disconnectAll(extra)
+		dsts = append(dsts, extra...)
+	}
+	files := 0
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	report := &TreeReport{}
+	failed := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr
+	}
+	finish := func(path string, res *CopyResult, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if res != nil {
+			report.Bytes += res.Bytes
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, TreeFailure{Path: path, Err: err})
+			if firstErr == nil && !opts.KeepGoing {
+				firstErr = err
+			}
+			return
+		}
+		report.Copied = append(report.Copied, path)
+	}
+
+	var walkFn WalkFunc = func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if !opts.KeepGoing {
+				return err
+			}
+			finish(path, nil, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := failed(); err != nil {
+			return err
+		}
+		if copyOpts.Context != nil {
+			if err := copyOpts.Context.Err(); err != nil {
+				return err
+			}
+		}
+		rel := strings.Trim(strings.TrimPrefix(strings.Trim(path, "/"), root), "/")
+		target := path2.Join(dstRoot, rel)
+		if info.IsDir() {
+			err := dst.MkdirAll(target)
+			if err != nil && opts.KeepGoing {
+				finish(path, nil, err)
+				return filepath.SkipDir
+			}
+			return err
+		}
+		from, to := srcs[files%len(srcs)], dsts[files%len(dsts)]
+		files++
+		slots <- struct{}{}
+		budget.acquire(footprint)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer budget.release(footprint)
+			res, err := CopyBetween(from, path, to, target, &copyOpts)
+			finish(path, res, err)
+		}()
+		return nil
+	}
+	if opts.Filter != nil {
+		walkFn = opts.Filter.walkFunc(srcRoot, walkFn, func(path string) {
+			mu.Lock()
+			report.Skipped = append(report.Skipped, path)
+			mu.Unlock()
+		})
+	}
+	walkErr := src.Walk(srcRoot, walkFn)
+	wg.Wait()
+	sort.Strings(report.Copied)
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].Path < report.Failed[j].Path })
+	if firstErr != nil {
+		return report, firstErr
+	}
+	return report, walkErr
+}
+
+// openConnections opens n sessions like s, logging on as login says.
+func openConnections(s *Smb, n int, login func(*SessionDescriptor) (string, string, error)) ([]*Smb, error) {
+	if login == nil {
+		return nil, errors.New("bulk connections need a login function")
+	}
+	d := s.Describe()
+	user, password, err := login(d)
+	if err != nil {
+		return nil, err
+	}
+	var opened []*Smb
+	for i := 0; i < n; i++ {
+		c, err := d.Open(user, password)
+		if err != nil {
+			disconnectAll(opened)
+			return nil, err
+		}
+		opened = append(opened, c)
+	}
+	return opened, nil
+}
+
+func disconnectAll(sessions []*Smb) {
+	for _, c := range sessions {
+		c.Disconnect()
+	}
+}
+
+// byteSemaphore is a counting semaphore over bytes. A request larger than
+// the whole budget is admitted alone rather than blocking forever.
+type byteSemaphore struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	size  int64
+	inUse int64
+}
+
+func newByteSemaphore(size int64) *byteSemaphore {
+	s := &byteSemaphore{size: size}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *byteSemaphore) acquire(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.inUse > 0 && s.inUse+n > s.size {
+		s.cond.Wait()
+	}
+	s.inUse += n
+}
+
+func (s *byteSemaphore) release(n int64) {
+	s.mu.Lock()
+	s.inUse -= n
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
@@ -0,0 +1,61 @@
+package libsmb2
+
+import (
+	"context"
+	"io"
+)
+
+// ReadContext is Read that returns as soon as ctx is done. libsmb2 calls
+// cannot be interrupted, so a read already sent is abandoned rather than
+// canceled: it completes in the background, its data is dropped and the
+// file position stays where it was. The next call on the session waits
+// for it to finish.
+func (f *smbFile) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	f.smb.mutex.Lock()
+	off := f.pos
+	f.smb.mutex.Unlock()
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	// The background read gets its own buffer, as p is the caller's
+	// again once the read is abandoned.
+	buf := make([]byte, len(p))
+	go func() {
+		var r result
+		r.err = f.smb.invokeN("read", f.path, len(buf), func() (int, error) {
+			r.n, r.err = f.readAt(buf, off)
+			return r.n, r.err
+		})
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		if r.n > 0 {
+			copy(p, buf[:r.n])
+			f.smb.mutex.Lock()
+			f.pos = off + int64(r.n)
+			f.smb.mutex.Unlock()
+		}
+		return r.n, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// ctxReader fails reads once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
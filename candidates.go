@@ -0,0 +1,81 @@
+package libsmb2
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+)
+
+// Attempt is one address Connect tried before the session was set up.
+type Attempt struct {
+	Addr     string
+	Err      error
+	Duration time.Duration
+}
+
+// MarshalJSON writes the error as its message.
+func (a Attempt) MarshalJSON() ([]byte, error) {
+	var msg string
+	if a.Err != nil {
+		msg = a.Err.Error()
+	}
+	return json.Marshal(struct {
+		Addr     string        `json:"addr"`
+		Err      string        `json:"error,omitempty"`
+		Duration time.Duration `json:"duration"`
+	}{a.Addr, msg, a.Duration})
+}
+
+// ConnectAttempts returns the addresses the last Connect probed, in the
+// order tried, with the successful one last. It is empty unless
+// ResolverOptions.Probe is set.
+func (s *Smb) ConnectAttempts() []Attempt {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Attempt(nil), s.attempts...)
+}
+
+// probe returns the first of addrs that accepts a TCP connection on port
+// within opts.Probe, recording every attempt. Must be called with s.mutex
+// held.
+func (s *Smb) probe(addrs []string, port string, opts *ResolverOptions) (string, error) {
+	if port == "" {
+		port = "445"
+	}
+	s.attempts = s.attempts[:0]
+	dial := func(addr string) Attempt {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, port), opts.Probe)
+		if err == nil {
+			conn.Close()
+		}
+		return Attempt{Addr: addr, Err: err, Duration: time.Since(start)}
+	}
+	if !opts.Parallel {
+		for _, addr := range addrs {
+			a := dial(addr)
+			s.attempts = append(s.attempts, a)
+			if a.Err == nil {
+				return addr, nil
+			}
+		}
+	} else {
+		results := make(chan Attempt, len(addrs))
+		for _, addr := range addrs {
+			go func(addr string) { results <- dial(addr) }(addr)
+		}
+		for range addrs {
+			a := <-results
+			s.attempts = append(s.attempts, a)
+			if a.Err == nil {
+				return a.Addr, nil
+			}
+		}
+	}
+	errs := make([]error, len(s.attempts))
+	for i, a := range s.attempts {
+		errs[i] = a.Err
+	}
+	return "", errors.Join(errs...)
+}
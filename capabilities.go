@@ -0,0 +1,36 @@
+package libsmb2
+
+//#include "libsmb2go.h"
+import "C"
+
+// Caps describes which optional features the bindings can use.
+type Caps struct {
+	// Kerberos authentication, see SetKerberos.
+	Kerberos bool
+	// Seal is SMB3 encryption, see SetSeal.
+	Seal bool
+	// Readlink resolves server side symbolic links.
+	Readlink bool
+	// Statvfs reports share capacity and free space.
+	Statvfs bool
+}
+
+// Capabilities reports the optional features available, so applications
+// can degrade gracefully across libsmb2 versions. On ELF platforms (Linux,
+// the BSDs) Seal, Readlink and Statvfs are detected when the program runs:
+// the bindings reference those functions weakly, so a distro libsmb2
+// without them still links and they are reported missing. Elsewhere
+// detection happens at build time only, and a libsmb2 lacking one needs
+// its LIBSMB2GO_HAVE_* macro defined to 0 in CGO_CFLAGS to link. Any
+// feature can be compiled out that way. Kerberos is off unless
+// LIBSMB2GO_HAVE_KRB5=1, since neither the headers nor the symbols tell
+// whether libsmb2 was built with GSSAPI.
+func Capabilities() Caps {
+	caps := C.libsmb2go_capabilities()
+	return Caps{
+		Kerberos: caps&C.LIBSMB2GO_CAP_KRB5 != 0,
+		Seal:     caps&C.LIBSMB2GO_CAP_SEAL != 0,
+		Readlink: caps&C.LIBSMB2GO_CAP_READLINK != 0,
+		Statvfs:  caps&C.LIBSMB2GO_CAP_STATVFS != 0,
+	}
+}
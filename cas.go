@@ -0,0 +1,138 @@
+package libsmb2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	path2 "path"
+)
+
+// ErrBadDigest is returned by Store for a digest that is not a hex string
+// of the store's hash length.
+var ErrBadDigest = errors.New("malformed digest")
+
+// Store is a content-addressable store in a directory of a share. Objects
+// are named by the hex digest of their content and sharded into two levels
+// of subdirectories by its first four digits, so no directory grows
+// unbounded. Storing the same content twice keeps one copy.
+type Store struct {
+	smb  *Smb
+	root string
+	// Hash is the digest function, SHA-256 unless set before first use.
+	Hash func() hash.Hash
+}
+
+// NewStore returns a Store rooted at root on s.
+func NewStore(s *Smb, root string) *Store {
+	return &Store{smb: s, root: root, Hash: sha256.New}
+}
+
+// path returns where the object named digest lives.
+func (st *Store) path(digest string) (string, error) {
+	raw, err := hex.DecodeString(digest)
+	if err != nil || len(raw) != st.Hash().Size() {
+		return "", ErrBadDigest
+	}
+	return path2.Join(st.root, digest[0:2], digest[2:4], digest), nil
+}
+
+// Put stores everything read from r and returns its digest. The content
+// is written to a temporary file first and renamed into place, so readers
+// never see a partial object.
+func (st *Store) Put(r io.Reader) (string, error) {
+	tmpDir := path2.Join(st.root, "tmp")
+	if err := st.smb.MkdirAll(tmpDir); err != nil {
+		return "", err
+	}
+	var name [16]byte
+	if _, err := rand.Read(name[:]); err != nil {
+		return "", err
+	}
+	tmp := path2.Join(tmpDir, hex.EncodeToString(name[:]))
+	f, err := st.smb.open(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", err
+	}
+	w, err := st.smb.contentWriter(f)
+	if err != nil {
+		removeCopied(st.smb, tmp)
+		return "", err
+	}
+	// The digest is of the content as given, whatever layers store it.
+	h := st.Hash()
+	if err := writeAndClose(w, io.TeeReader(r, h)); err != nil {
+		removeCopied(st.smb, tmp)
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	target, _ := st.path(digest)
+	if err := st.smb.MkdirAll(path2.Dir(target)); err != nil {
+		removeCopied(st.smb, tmp)
+		return "", err
+	}
+	if err := st.smb.Rename(tmp, target); err != nil {
+		removeCopied(st.smb, tmp)
+		if errors.Is(err, os.ErrExist) {
+			return digest, nil
+		}
+		return "", err
+	}
+	return digest, nil
+}
+
+// Has reports whether the object named digest is stored.
+func (st *Store) Has(digest string) (bool, error) {
+	p, err := st.path(digest)
+	if err != nil {
+		return false, err
+	}
+	_, err = st.smb.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get opens the object named digest. Its content is hashed as it is read,
+// and the read that reaches the end fails with ErrVerifyFailed instead of
+// io.EOF if the object does not match its name.
+func (st *Store) Get(digest string) (io.ReadCloser, error) {
+	p, err := st.path(digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := st.smb.open(p, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	r, err := st.smb.contentReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{r: r, smb: st.smb, path: p, h: st.Hash(), want: digest}, nil
+}
+
+type verifyingReader struct {
+	r    io.ReadCloser
+	smb  *Smb
+	path string
+	h    hash.Hash
+	want string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.h.Sum(nil)) != v.want {
+		return n, v.smb.wrapError("verify", v.path, ErrVerifyFailed)
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.r.Close()
+}
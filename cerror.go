@@ -0,0 +1,24 @@
+package libsmb2
+
+//#include "libsmb2go.h"
+import "C"
+
+// errBufLen bounds the libsmb2 error text captured for one operation.
+const errBufLen = 256
+
+// cError receives the libsmb2 error text of a single operation. The C
+// wrappers fill it right after the failing call, so concurrent operations
+// on one context never report each other's message.
+type cError [errBufLen]C.char
+
+func (e *cError) ptr() *C.char {
+	return &e[0]
+}
+
+func (e *cError) len() C.size_t {
+	return errBufLen
+}
+
+func (e *cError) String() string {
+	return C.GoString(&e[0])
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+func runBench(args []string) error {
+	var conn connFlags
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	conn.register(fs)
+	op := fs.String("op", "read", "benchmark to run: read, write or stat")
+	pattern := fs.String("pattern", "seq", "access pattern for read and write: seq or rand")
+	path := fs.String("path", "smbgo-bench.dat", "file on the share to benchmark against")
+	block := fs.Int("block", 64*1024, "bytes per read or write")
+	size := fs.Int64("size", 64*1024*1024, "file size used by write and by random reads")
+	count := fs.Int("count", 0, "number of operations, 0 runs until -duration")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run when -count is 0")
+	fs.Parse(args)
+
+	if *block <= 0 {
+		return errors.New("-block must be positive")
+	}
+	smb, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer smb.Disconnect()
+
+	var step func(i int) (int, error)
+	var cleanup func()
+	switch *op {
+	case "read", "write":
+		flags := os.O_RDONLY
+		if *op == "write" {
+			flags = os.O_RDWR | os.O_CREATE
+		}
+		f, err := smb.OpenFile(*path, flags)
+		if err != nil {
+			return err
+		}
+		cleanup = func() { f.Close() }
+		fileSize := *size
+		if *op == "read" {
+			fileSize = f.Size()
+		}
+		blocks := fileSize / int64(*block)
+		if blocks < 1 {
+			blocks = 1
+		}
+		buf := make([]byte, *block)
+		rand.Read(buf)
+		step = func(i int) (int, error) {
+			var off int64
+			if *pattern == "rand" {
+				off = rand.Int63n(blocks) * int64(*block)
+			} else {
+				off = (int64(i) % blocks) * int64(*block)
+			}
+			if _, err := f.Seek(off, io.SeekStart); err != nil {
+				return 0, err
+			}
+			if *op == "write" {
+				return f.Write(buf)
+			}
+			n, err := f.Read(buf)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+			return n, err
+		}
+	case "stat":
+		step = func(i int) (int, error) {
+			_, err := smb.Stat(*path)
+			return 0, err
+		}
+	default:
+		return fmt.Errorf("unknown -op %q", *op)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	var latencies []time.Duration
+	var bytes int64
+	start := time.Now()
+	for i := 0; ; i++ {
+		if *count > 0 && i >= *count {
+			break
+		}
+		if *count <= 0 && time.Since(start) >= *duration {
+			break
+		}
+		t := time.Now()
+		n, err := step(i)
+		if err != nil {
+			return fmt.Errorf("%s %s after %d operations: %v", *op, *path, i, err)
+		}
+		latencies = append(latencies, time.Since(t))
+		bytes += int64(n)
+	}
+	report(os.Stdout, *op, *pattern, time.Since(start), bytes, latencies)
+	return nil
+}
+
+func report(w io.Writer, op string, pattern string, elapsed time.Duration, bytes int64, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		fmt.Fprintln(w, "no operations completed")
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pct := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	secs := elapsed.Seconds()
+	fmt.Fprintf(w, "op=%s pattern=%s ops=%d elapsed=%s\n", op, pattern, len(latencies), elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput: %.1f ops/s, %.2f MiB/s\n", float64(len(latencies))/secs, float64(bytes)/secs/(1<<20))
+	fmt.Fprintf(w, "latency: min=%s p50=%s p90=%s p99=%s max=%s\n",
+		latencies[0], pct(0.50), pct(0.90), pct(0.99), latencies[len(latencies)-1])
+}
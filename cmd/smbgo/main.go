@@ -0,0 +1,83 @@
+// Command smbgo is a small command line client built on the libsmb2
+// bindings.
+//
+// Usage:
+//
+//	smbgo <command> [flags]
+//
+// The commands are:
+//
+//	bench    run read, write and metadata benchmarks against a share
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	libsmb2 "github.com/cyolosecurity/libsmb2-go"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"bench", "run read, write and metadata benchmarks against a share", runBench},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: smbgo <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.short)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "smbgo %s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
+
+// connFlags are the flags shared by every command that talks to a share.
+type connFlags struct {
+	host     string
+	share    string
+	user     string
+	password string
+}
+
+func (c *connFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.host, "host", "", "server to connect to")
+	fs.StringVar(&c.share, "share", "", "share to connect to")
+	fs.StringVar(&c.user, "user", "guest", "user name")
+	fs.StringVar(&c.password, "password", "", "password, defaults to $SMB_PASSWORD")
+}
+
+func (c *connFlags) connect() (*libsmb2.Smb, error) {
+	if c.host == "" || c.share == "" {
+		return nil, fmt.Errorf("-host and -share are required")
+	}
+	password := c.password
+	if password == "" {
+		password = os.Getenv("SMB_PASSWORD")
+	}
+	smb := libsmb2.NewSmb()
+	if err := smb.Connect(c.host, c.share, c.user, password); err != nil {
+		return nil, err
+	}
+	return smb, nil
+}
@@ -0,0 +1,52 @@
+package libsmb2
+
+import (
+	"os"
+	"time"
+)
+
+// fatGranularity is the modification time resolution of FAT file systems.
+// Writers round up to the next even second, so a copy can be up to two
+// seconds newer than its source.
+const fatGranularity = 2 * time.Second
+
+// TimeComparison compares modification times across systems that store
+// them with different precision.
+type TimeComparison struct {
+	// Tolerance is the largest difference still considered equal.
+	Tolerance time.Duration
+	// FAT widens the tolerance to the two second granularity of FAT
+	// volumes, common behind legacy shares and NAS USB ports.
+	FAT bool
+}
+
+func (c TimeComparison) tolerance() time.Duration {
+	if c.FAT && c.Tolerance < fatGranularity {
+		return fatGranularity
+	}
+	return c.Tolerance
+}
+
+// Equal reports whether a and b are the same within the tolerance. An unset
+// (zero) time only equals another unset time.
+func (c TimeComparison) Equal(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() && b.IsZero()
+	}
+	d := a.Sub(b)
+	if d < 0 {
+		d = -d
+	}
+	return d <= c.tolerance()
+}
+
+// After reports whether a is later than b by more than the tolerance.
+func (c TimeComparison) After(a, b time.Time) bool {
+	return a.Sub(b) > c.tolerance()
+}
+
+// Changed reports whether dst needs to be refreshed from src: they differ
+// in size, or in modification time beyond the tolerance.
+func (c TimeComparison) Changed(src, dst os.FileInfo) bool {
+	return src.Size() != dst.Size() || !c.Equal(src.ModTime(), dst.ModTime())
+}
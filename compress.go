@@ -0,0 +1,138 @@
+package libsmb2
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// cmpMagic starts every stream written by a compressing session, ahead of
+// the compressor's own. Files are only decompressed when they carry it,
+// so a .gz stored as is reads back unchanged.
+const cmpMagic = "SMB2GOZ1"
+
+// errCompressor reports a compressed file written with another format
+// than the session's.
+var errCompressor = errors.New("file compressed with another format")
+
+// Compressor is a compression format for SetCompression. Magic is the
+// signature its streams start with, used to check that a compressed file
+// is in this format.
+type Compressor interface {
+	Magic() []byte
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip returns a Compressor writing gzip at level, one of the
+// compress/gzip levels. Formats outside the standard library, like zstd,
+// plug in by implementing Compressor.
+func Gzip(level int) Compressor {
+	return gzipCompressor(level)
+}
+
+type gzipCompressor int
+
+func (gzipCompressor) Magic() []byte {
+	return []byte{0x1f, 0x8b}
+}
+
+func (c gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, int(c))
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// SetCompression compresses file content written through Upload,
+// WriteFile and Create with c, and decompresses content read through
+// ReadFile, OpenReader and Download when it starts with the header the
+// package writes in front of compressed content, so files stored before
+// compression was turned on, compressed archives included, still read as
+// they are. With encryption also set, content is compressed before it is
+// sealed. nil turns compression off.
+func (s *Smb) SetCompression(c Compressor) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.compression = c
+}
+
+// compressWriter writes the header to w and compresses into it; closing
+// it closes w as well.
+func compressWriter(w io.WriteCloser, c Compressor) (io.WriteCloser, error) {
+	if err := writeFull(w, []byte(cmpMagic)); err != nil {
+		return nil, err
+	}
+	cw, err := c.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &stackedWriter{WriteCloser: cw, under: w}, nil
+}
+
+// decompressReader decompresses r if it starts with the header and passes
+// it through otherwise; closing it closes r as well.
+func decompressReader(r io.ReadCloser, c Compressor) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(cmpMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if string(head) != cmpMagic {
+		return &stackedReader{Reader: br, under: r}, nil
+	}
+	br.Discard(len(cmpMagic))
+	magic := c.Magic()
+	if head, err := br.Peek(len(magic)); err == nil && !bytes.Equal(head, magic) {
+		return nil, errCompressor
+	}
+	cr, err := c.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &stackedReader{Reader: cr, top: cr, under: r}, nil
+}
+
+// stackedWriter closes a layer and then the writer beneath it.
+type stackedWriter struct {
+	io.WriteCloser
+	under io.Closer
+}
+
+// Flush pushes what the layer buffers down to the writer beneath it, for
+// layers that can.
+func (w *stackedWriter) Flush() error {
+	if f, ok := w.WriteCloser.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return ErrNotSupported
+}
+
+func (w *stackedWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if uerr := w.under.Close(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// stackedReader closes a layer, if any, and then the reader beneath it.
+type stackedReader struct {
+	io.Reader
+	top   io.Closer
+	under io.Closer
+}
+
+func (r *stackedReader) Close() error {
+	var err error
+	if r.top != nil {
+		err = r.top.Close()
+	}
+	if uerr := r.under.Close(); err == nil {
+		err = uerr
+	}
+	return err
+}
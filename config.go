@@ -0,0 +1,151 @@
+package libsmb2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ConfigOptions tunes LoadConfig.
+type ConfigOptions struct {
+	// New returns a fresh pointer to decode the file into. Required.
+	New func() any
+	// Decode parses the file, json.Unmarshal by default; pass
+	// yaml.Unmarshal of a YAML package for YAML files.
+	Decode func(data []byte, v any) error
+	// Validate, when set, rejects a decoded value; a rejected reload
+	// keeps the previous value.
+	Validate func(v any) error
+	// Poll is how often the file is checked for changes, default 10
+	// seconds. libsmb2 has no change notification to wait on.
+	Poll time.Duration
+	// Debounce is how long a changed file must stay unchanged before it
+	// is reloaded, default 2 seconds, so a file still being saved is not
+	// read half written.
+	Debounce time.Duration
+	// OnChange is called with each value reloaded after a change.
+	OnChange func(v any)
+	// OnError is called when a reload fails to read, decode or validate.
+	OnError func(err error)
+}
+
+// ConfigWatcher holds the current value of a configuration file kept on a
+// share.
+type ConfigWatcher struct {
+	smb   *Smb
+	path  string
+	opts  ConfigOptions
+	mutex sync.Mutex
+	value any
+	etag  string
+}
+
+// LoadConfig reads, decodes and validates the configuration file at path,
+// failing if any of that does, then keeps reloading it in the background
+// whenever it changes until ctx is done.
+func (s *Smb) LoadConfig(ctx context.Context, path string, opts *ConfigOptions) (*ConfigWatcher, error) {
+	if opts == nil || opts.New == nil {
+		return nil, errors.New("config loader needs a New function")
+	}
+	w := &ConfigWatcher{smb: s, path: path, opts: *opts}
+	if w.opts.Decode == nil {
+		w.opts.Decode = json.Unmarshal
+	}
+	if w.opts.Poll <= 0 {
+		w.opts.Poll = 10 * time.Second
+	}
+	if w.opts.Debounce <= 0 {
+		w.opts.Debounce = 2 * time.Second
+	}
+	info, err := s.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.value, w.etag = v, ETag(info)
+	go w.watch(ctx)
+	return w, nil
+}
+
+// Current returns the last value loaded successfully.
+func (w *ConfigWatcher) Current() any {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.value
+}
+
+func (w *ConfigWatcher) load() (any, error) {
+	data, err := w.smb.ReadFile(w.path)
+	if err != nil {
+		return nil, err
+	}
+	v := w.opts.New()
+	if err := w.opts.Decode(data, v); err != nil {
+		return nil, err
+	}
+	if w.opts.Validate != nil {
+		if err := w.opts.Validate(v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (w *ConfigWatcher) watch(ctx context.Context) {
+	// pending is the ETag of a change waiting out the debounce time.
+	var pending string
+	var since time.Time
+	for {
+		wait := w.opts.Poll
+		if pending != "" {
+			wait = w.opts.Debounce
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		info, err := w.smb.Stat(w.path)
+		if err != nil {
+			// A file replaced by delete and rename is missing for a
+			// moment; wait for it to come back.
+			pending = ""
+			continue
+		}
+		etag := ETag(info)
+		w.mutex.Lock()
+		current := w.etag
+		w.mutex.Unlock()
+		switch {
+		case etag == current:
+			pending = ""
+			continue
+		case etag != pending:
+			pending, since = etag, time.Now()
+			continue
+		case time.Since(since) < w.opts.Debounce:
+			continue
+		}
+		pending = ""
+		v, err := w.load()
+		w.mutex.Lock()
+		// A broken file is not retried until it changes again.
+		w.etag = etag
+		if err == nil {
+			w.value = v
+		}
+		w.mutex.Unlock()
+		if err != nil {
+			if w.opts.OnError != nil {
+				w.opts.OnError(err)
+			}
+		} else if w.opts.OnChange != nil {
+			w.opts.OnChange(v)
+		}
+	}
+}
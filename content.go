@@ -0,0 +1,163 @@
+package libsmb2
+
+import (
+	"io"
+	"os"
+)
+
+// OpenReader opens path for reading its content, undoing the session's
+// encryption and compression if set.
+func (s *Smb) OpenReader(path string) (io.ReadCloser, error) {
+	f, err := s.open(path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return s.contentReader(f)
+}
+
+// Download copies the content of path to w. DownloadResumable can pick up
+// an interrupted download to a local file where it stopped.
+func (s *Smb) Download(path string, w io.Writer) error {
+	r, err := s.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// encrypted reports whether the session seals file content, which raw
+// file access would bypass.
+func (s *Smb) encrypted() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encryption != nil
+}
+
+// layered reports whether file content is transformed on the client.
+func (s *Smb) layered() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encryption != nil || s.compression != nil
+}
+
+// contentWriter wraps f in the content layers of the session: compression
+// over encryption over the file. f is closed if that fails. A layered
+// writer is tracked by the session so Barrier and Shutdown can flush it.
+func (s *Smb) contentWriter(f *smbFile) (io.WriteCloser, error) {
+	s.mutex.Lock()
+	kp, c := s.encryption, s.compression
+	s.mutex.Unlock()
+	if kp == nil && c == nil {
+		return f, nil
+	}
+	lw := &layeredWriter{smb: s, file: f, layers: []io.WriteCloser{f}}
+	var w io.WriteCloser = f
+	var err error
+	if kp != nil {
+		if w, err = newSealWriter(w, kp); err != nil {
+			f.Close()
+			return nil, s.wrapError("write", f.path, err)
+		}
+		lw.layers = append(lw.layers, w)
+	}
+	if c != nil {
+		if w, err = compressWriter(w, c); err != nil {
+			f.Close()
+			return nil, s.wrapError("write", f.path, err)
+		}
+		lw.layers = append(lw.layers, w)
+	}
+	s.mutex.Lock()
+	if s.writers == nil {
+		s.writers = make(map[*layeredWriter]struct{})
+	}
+	s.writers[lw] = struct{}{}
+	s.mutex.Unlock()
+	return lw, nil
+}
+
+// contentReader is the reading side of contentWriter.
+func (s *Smb) contentReader(f *smbFile) (io.ReadCloser, error) {
+	s.mutex.Lock()
+	kp, c := s.encryption, s.compression
+	s.mutex.Unlock()
+	var r io.ReadCloser = f
+	var err error
+	if kp != nil {
+		if r, err = newOpenReader(r, kp); err != nil {
+			f.Close()
+			return nil, s.wrapError("read", f.path, err)
+		}
+	}
+	if c != nil {
+		if r, err = decompressReader(r, c); err != nil {
+			f.Close()
+			return nil, s.wrapError("read", f.path, err)
+		}
+	}
+	return r, nil
+}
+
+// DownloadSparse copies path to w like Download, but seeks over blocks
+// that are all zeros instead of writing them, so a local file receiving
+// a sparse VM disk or database file keeps its holes. If w has a Truncate
+// method, which an *os.File does, it is emptied first, so no old data
+// shows through the holes, and truncated to the full size at the end, so
+// a trailing hole is not lost; any other w must be empty. The holes still
+// cross the network: libsmb2 cannot ask the server for allocated ranges.
+func (s *Smb) DownloadSparse(path string, w io.WriteSeeker) error {
+	r, err := s.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	t, truncates := w.(interface{ Truncate(int64) error })
+	if truncates {
+		if err := t.Truncate(0); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	block := int(s.Limits().MaxReadSize)
+	if block <= 0 {
+		block = defaultCopyBuffer
+	}
+	buf := make([]byte, block)
+	var size int64
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			if allZero(buf[:n]) {
+				_, err = w.Seek(int64(n), io.SeekCurrent)
+			} else {
+				err = writeFull(w, buf[:n])
+			}
+			if err != nil {
+				return err
+			}
+			size += int64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		} else if rerr != nil {
+			return rerr
+		}
+	}
+	if truncates {
+		return t.Truncate(size)
+	}
+	return nil
+}
+
+func allZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
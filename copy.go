@@ -0,0 +1,310 @@
+package libsmb2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// defaultCopyBuffer is used when the source did not negotiate a read size.
+	defaultCopyBuffer = 1 << 20
+	// copyDepth is the number of buffers in flight between reader and writer.
+	copyDepth = 4
+)
+
+// ErrVerifyFailed is returned by CopyBetween when the destination does not
+// read back with the hash of what was written.
+var ErrVerifyFailed = errors.New("copy verification failed")
+
+// CopyOptions tunes CopyBetween. The zero value is a plain copy.
+type CopyOptions struct {
+	// BufferSize is the size of each read, defaulting to the source's
+	// negotiated maximum read size. A copy holds four buffers of it.
+	BufferSize int
+	// Hash, when set, is fed everything copied; its sum is returned in
+	// CopyResult.Sum.
+	Hash func() hash.Hash
+	// Verify reads the destination back after the copy and compares its
+	// hash with the source's. Requires Hash.
+	Verify bool
+	// Resume records progress in a sidecar next to the destination
+	// (dstPath + ".smbresume") and, when a previous copy of the same
+	// unchanged source was interrupted, continues after the last chunk
+	// that still verifies instead of starting over. The sidecar is removed
+	// once the copy completes.
+	Resume bool
+	// BandwidthLimit caps the copy at this many bytes per second, 0 is
+	// unlimited.
+	BandwidthLimit int64
+	// Events, when set, is told when each file starts, after every chunk
+	// written, and when it is done or failed. CopyTree calls it from
+	// several goroutines at once.
+	Events func(TransferEvent)
+	// Context, when set, cancels the copy: once it is done no further
+	// chunk is read or written and the copy fails with its error. A
+	// request already sent finishes first, as libsmb2 calls cannot be
+	// interrupted. CopyTree also stops starting new files.
+	Context context.Context
+}
+
+// CopyResult describes a finished copy.
+type CopyResult struct {
+	Bytes int64
+	Sum   []byte
+}
+
+// CopyBetween copies srcPath on src to dstPath on dst, which may be
+// sessions to different servers. Reads and writes overlap: up to four
+// buffers are read ahead while the destination writes. The destination is
+// created or truncated, keeping a version first if dst has versioning on.
+// libsmb2 cannot set timestamps or security descriptors, so the copy gets
+// the server's defaults for both.
+func CopyBetween(src *Smb, srcPath string, dst *Smb, dstPath string, opts *CopyOptions) (*CopyResult, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	emit := func(kind EventKind, copied, size int64, err error) {
+		if opts.Events != nil {
+			opts.Events(TransferEvent{Kind: kind, Src: srcPath, Dst: dstPath, Bytes: copied, Size: size, Err: err})
+		}
+	}
+	var size int64
+	res, err := copyBetween(src, srcPath, dst, dstPath, opts, func(kind EventKind, copied, n int64) {
+		size = n
+		emit(kind, copied, n, nil)
+	})
+	var copied int64
+	if res != nil {
+		copied = res.Bytes
+	}
+	if err != nil {
+		emit(FileFailed, copied, size, err)
+	} else {
+		emit(FileDone, copied, size, nil)
+	}
+	return res, err
+}
+
+func copyBetween(src *Smb, srcPath string, dst *Smb, dstPath string, opts *CopyOptions, progress func(kind EventKind, copied, size int64)) (*CopyResult, error) {
+	if opts.Verify && opts.Hash == nil {
+		return nil, errors.New("copy verification needs a hash")
+	}
+	size := opts.BufferSize
+	if size <= 0 {
+		size = int(src.Limits().MaxReadSize)
+	}
+	if size <= 0 {
+		size = defaultCopyBuffer
+	}
+
+	in, err := src.open(srcPath, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	progress(FileStarted, 0, in.Size())
+
+	var tracker *chunkTracker
+	var offset int64
+	if opts.Resume {
+		var state *resumeState
+		state, offset = loadResume(dst, dstPath, srcPath, in)
+		save := func(st *resumeState) error { return saveResume(dst, dstPath, st) }
+		tracker = &chunkTracker{save: save, state: state, h: sha256.New()}
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY
+	} else {
+		dst.mutex.Lock()
+		err = dst.keepVersion(dstPath)
+		dst.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	out, err := dst.open(dstPath, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	var h hash.Hash
+	if opts.Hash != nil {
+		h = opts.Hash()
+	}
+	res := &CopyResult{}
+	throttle := newThrottle(opts.BandwidthLimit)
+	if offset > 0 {
+		err = resumeAt(in, out, dst, dstPath, offset, h)
+		res.Bytes = offset
+	}
+	var from io.Reader = in
+	if opts.Context != nil {
+		from = ctxReader{ctx: opts.Context, r: in}
+	}
+	if err == nil {
+		err = pipeline(from, out, size, func(p []byte) error {
+			res.Bytes += int64(len(p))
+			throttle.wait(len(p))
+			progress(FileProgress, res.Bytes, in.Size())
+			if h != nil {
+				h.Write(p)
+			}
+			if tracker != nil {
+				if err := tracker.add(p); err != nil {
+					return err
+				}
+			}
+			if opts.Context != nil {
+				return opts.Context.Err()
+			}
+			return nil
+		})
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return res, err
+	}
+	if tracker != nil {
+		if err := removeResume(dst, dstPath); err != nil {
+			return res, err
+		}
+	}
+	if h != nil {
+		res.Sum = h.Sum(nil)
+	}
+	if opts.Verify {
+		if err := verifyCopy(dst, dstPath, opts.Hash(), res.Sum); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// pipeline copies in to out, reading ahead into a ring of buffers while
+// out writes. written is called with every chunk once it is on out.
+func pipeline(in io.Reader, out io.Writer, size int, written func(p []byte) error) error {
+	type chunk struct {
+		buf []byte
+		err error
+	}
+	free := make(chan []byte, copyDepth)
+	for i := 0; i < copyDepth; i++ {
+		free <- make([]byte, size)
+	}
+	full := make(chan chunk, copyDepth)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(full)
+		send := func(c chunk) bool {
+			select {
+			case full <- c:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		for {
+			var buf []byte
+			select {
+			case buf = <-free:
+			case <-done:
+				return
+			}
+			n, err := in.Read(buf)
+			if n > 0 && !send(chunk{buf: buf[:n]}) {
+				return
+			}
+			if err != nil {
+				if err != io.EOF {
+					send(chunk{err: err})
+				}
+				return
+			}
+		}
+	}()
+
+	for c := range full {
+		if c.err != nil {
+			return c.err
+		}
+		if err := writeFull(out, c.buf); err != nil {
+			return err
+		}
+		if err := written(c.buf); err != nil {
+			return err
+		}
+		free <- c.buf[:cap(c.buf)]
+	}
+	return nil
+}
+
+// throttle paces a transfer to a byte rate.
+type throttle struct {
+	rate  int64
+	start time.Time
+	bytes int64
+}
+
+func newThrottle(rate int64) *throttle {
+	return &throttle{rate: rate, start: time.Now()}
+}
+
+// wait accounts for n more bytes and sleeps until the transfer is back
+// under the rate.
+func (t *throttle) wait(n int) {
+	if t.rate <= 0 {
+		return
+	}
+	t.bytes += int64(n)
+	due := t.start.Add(time.Duration(t.bytes * int64(time.Second) / t.rate))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// resumeAt positions in and out at offset and feeds the already copied
+// prefix to h, so the final sum still covers the whole file.
+func resumeAt(in *smbFile, out *smbFile, dst *Smb, dstPath string, offset int64, h hash.Hash) error {
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if h == nil {
+		return nil
+	}
+	f, err := dst.open(dstPath, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(h, f, offset)
+	return err
+}
+
+func verifyCopy(s *Smb, path string, h hash.Hash, want []byte) error {
+	f, err := s.open(path, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), want) {
+		return s.wrapError("verify", path, ErrVerifyFailed)
+	}
+	return nil
+}
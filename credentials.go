@@ -0,0 +1,47 @@
+package libsmb2
+
+import (
+	"strings"
+	"unsafe"
+)
+
+//#include <stdlib.h>
+//#include <string.h>
+//#include "libsmb2go.h"
+import "C"
+
+const redacted = "[REDACTED]"
+
+// SetZeroizeCredentials controls whether the password is dropped from the
+// libsmb2 context once the session is set up. The bindings always wipe
+// their own C copy of the password; with this enabled libsmb2 frees its
+// copy as well, at the cost of not being able to authenticate again on
+// the same context. Despite the name, that copy is only freed: libsmb2
+// does not overwrite it and offers no way to reach it, and the password
+// hashes and Kerberos material derived during session setup stay in its
+// context until Disconnect.
+func (s *Smb) SetZeroizeCredentials(on bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.zeroizeCredentials = on
+}
+
+// wipeCString overwrites a C string allocated by C.CString and frees it.
+func wipeCString(p *C.char) {
+	if p == nil {
+		return
+	}
+	C.memset(unsafe.Pointer(p), 0, C.strlen(p))
+	C.free(unsafe.Pointer(p))
+}
+
+// redact replaces every occurrence of the non-empty secrets in msg, so
+// messages coming back from libsmb2 can be put in errors and logs.
+func redact(msg string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			msg = strings.ReplaceAll(msg, secret, redacted)
+		}
+	}
+	return msg
+}
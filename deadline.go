@@ -0,0 +1,67 @@
+package libsmb2
+
+import (
+	"os"
+	"time"
+)
+
+//#include "libsmb2go.h"
+import "C"
+
+// SetTimeout makes every call on the session fail with
+// os.ErrDeadlineExceeded when the server has not replied within d; 0,
+// the default, waits indefinitely. libsmb2 counts in whole seconds, so d
+// is rounded up to one.
+func (s *Smb) SetTimeout(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.timeout = d
+	if s.session != nil {
+		C.smb2_set_timeout(s.session, C.int(timeoutSeconds(d)))
+	}
+}
+
+// SetDeadline sets an absolute time after which reads, writes, directory
+// reads and flushes of f fail with os.ErrDeadlineExceeded, as on a
+// net.Conn. It applies on top of the session timeout, so a latency
+// critical path can use a tighter limit than background jobs sharing the
+// session. The zero time removes it. A call already past the deadline
+// fails without reaching the server; one in flight is bounded to the
+// second, the granularity of libsmb2's timeout.
+func (f *smbFile) SetDeadline(t time.Time) error {
+	f.smb.mutex.Lock()
+	defer f.smb.mutex.Unlock()
+	f.deadline = t
+	return nil
+}
+
+// applyDeadline narrows the session timeout to f's deadline for the next
+// libsmb2 call and returns the function restoring it. Must be called with
+// f.smb.mutex held.
+func (f *smbFile) applyDeadline(op string) (restore func(), err error) {
+	if f.deadline.IsZero() {
+		return func() {}, nil
+	}
+	left := time.Until(f.deadline)
+	if left <= 0 {
+		return nil, f.smb.wrapError(op, f.path, os.ErrDeadlineExceeded)
+	}
+	s := f.smb
+	if s.timeout > 0 && s.timeout <= left {
+		return func() {}, nil
+	}
+	C.smb2_set_timeout(s.session, C.int(timeoutSeconds(left)))
+	return func() {
+		if s.session != nil {
+			C.smb2_set_timeout(s.session, C.int(timeoutSeconds(s.timeout)))
+		}
+	}, nil
+}
+
+// timeoutSeconds rounds d up to the whole seconds libsmb2 takes.
+func timeoutSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,125 @@
+package libsmb2
+
+import (
+	"time"
+)
+
+// SessionDescriptor describes a session well enough for another process
+// to open an equivalent one: where it connects and the settings it was
+// given. It never holds credentials; whoever opens it supplies their own.
+// Settings that are Go functions or objects (middleware, path rewriter,
+// hidden filter, encryption, compression, name normalization, a custom
+// resolver, affinity, fault injection and loggers) cannot cross a process
+// boundary and must be installed again on the new session. It marshals to
+// and from JSON as is.
+type SessionDescriptor struct {
+	Host               string             `json:"host"`
+	Share              string             `json:"share"`
+	Seal               bool               `json:"seal,omitempty"`
+	Kerberos           bool               `json:"kerberos,omitempty"`
+	Strict             bool               `json:"strict,omitempty"`
+	ZeroizeCredentials bool               `json:"zeroize_credentials,omitempty"`
+	SFM                bool               `json:"sfm,omitempty"`
+	ReservedNames      ReservedNamePolicy `json:"reserved_names,omitempty"`
+	RecycleDir         string             `json:"recycle_dir,omitempty"`
+	Versioning         *Versioning        `json:"versioning,omitempty"`
+	Readdir            ReaddirOptions     `json:"readdir"`
+	Tags               map[string]string  `json:"tags,omitempty"`
+	HealthProbe        string             `json:"health_probe,omitempty"`
+	MaxHandles         int                `json:"max_handles,omitempty"`
+	WaitHandles        bool               `json:"wait_handles,omitempty"`
+	IdleTimeout        time.Duration      `json:"idle_timeout,omitempty"`
+	Timeout            time.Duration      `json:"timeout,omitempty"`
+	SlowOpThreshold    time.Duration      `json:"slow_op_threshold,omitempty"`
+	Resolver           *ResolverOptions   `json:"resolver,omitempty"`
+}
+
+// Describe returns the descriptor of the session. The host and share
+// are those of the last Connect.
+func (s *Smb) Describe() *SessionDescriptor {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d := &SessionDescriptor{
+		Host:               s.host,
+		Share:              s.share,
+		Seal:               s.seal,
+		Kerberos:           s.kerberos,
+		Strict:             s.strict,
+		ZeroizeCredentials: s.zeroizeCredentials,
+		SFM:                s.names.SFM,
+		ReservedNames:      s.reservedNames,
+		RecycleDir:         s.recycleDir,
+		Readdir:            s.readdir,
+		HealthProbe:        s.healthProbe,
+		MaxHandles:         s.maxHandles,
+		WaitHandles:        s.waitHandles,
+		IdleTimeout:        s.idleTimeout,
+		Timeout:            s.timeout,
+		SlowOpThreshold:    s.slowThreshold,
+	}
+	if s.versioning != nil {
+		v := *s.versioning
+		d.Versioning = &v
+	}
+	if len(s.tags) > 0 {
+		d.Tags = make(map[string]string, len(s.tags))
+		for k, v := range s.tags {
+			d.Tags[k] = v
+		}
+	}
+	if s.resolver != nil {
+		d.Resolver = &ResolverOptions{
+			Hosts:    s.resolver.Hosts,
+			Prefer:   s.resolver.Prefer,
+			Probe:    s.resolver.Probe,
+			Parallel: s.resolver.Parallel,
+		}
+	}
+	return d
+}
+
+// Open creates a session with the settings of d and connects it as user.
+func (d *SessionDescriptor) Open(user string, password string) (*Smb, error) {
+	s := NewSmb()
+	if d.Seal {
+		if err := s.SetSeal(true); err != nil {
+			s.Disconnect()
+			return nil, err
+		}
+	}
+	if d.Kerberos {
+		if err := s.SetKerberos(true); err != nil {
+			s.Disconnect()
+			return nil, err
+		}
+	}
+	if d.Strict {
+		if err := s.SetStrict(true); err != nil {
+			s.Disconnect()
+			return nil, err
+		}
+	}
+	s.SetZeroizeCredentials(d.ZeroizeCredentials)
+	s.SetNameMapping(NameMapping{SFM: d.SFM})
+	s.SetReservedNamePolicy(d.ReservedNames)
+	s.SetRecycleDir(d.RecycleDir)
+	if d.Versioning != nil {
+		v := *d.Versioning
+		s.SetVersioning(&v)
+	}
+	s.SetReaddirOptions(d.Readdir)
+	s.SetTags(d.Tags)
+	s.SetHealthProbe(d.HealthProbe)
+	s.SetMaxHandles(d.MaxHandles, d.WaitHandles)
+	s.SetSlowOpThreshold(d.SlowOpThreshold, nil)
+	s.SetTimeout(d.Timeout)
+	if d.Resolver != nil {
+		r := *d.Resolver
+		s.SetResolver(&r)
+	}
+	if err := s.Connect(d.Host, d.Share, user, password); err != nil {
+		return nil, err
+	}
+	s.SetIdleTimeout(d.IdleTimeout)
+	return s, nil
+}
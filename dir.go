@@ -0,0 +1,71 @@
+package libsmb2
+
+import (
+	"os"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+//#include <stdlib.h>
+//#include "libsmb2go.h"
+import "C"
+
+// readDir lists dir in one pass over a single directory handle, sorted by
+// name and without "." and "..". Must be called with s.mutex held.
+func (s *Smb) readDir(dir string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := s.scanDir(dir, func(info os.FileInfo) { infos = append(infos, info) })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// scanDir calls fn with every entry of dir but "." and "..", in the
+// order the server returns them, without collecting them. Must be called
+// with s.mutex held.
+func (s *Smb) scanDir(dir string, fn func(os.FileInfo)) error {
+	if s.session == nil {
+		return s.wrapError("readdir", dir, ErrClosed)
+	}
+	defer s.logSlow("readdir", dir, 0, time.Now())
+	if err := s.injectFault("readdir", dir, 0); err != nil {
+		return s.wrapError("readdir", dir, err)
+	}
+	cPath, err := s.cPath("readdir", dir)
+	if err != nil {
+		return err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	var cerr cError
+	list := C.smb2_opendir_wrapper(s.session, cPath, cerr.ptr(), cerr.len())
+	if list == nil {
+		return s.newError("readdir", dir, 0, cerr.String())
+	}
+	defer C.smb2_closedir(s.session, list)
+	for ent := C.smb2_readdir(s.session, list); ent != nil; ent = C.smb2_readdir(s.session, list) {
+		name := s.clientName(C.GoString(ent.name))
+		if name == "." || name == ".." {
+			continue
+		}
+		st := cSmbStat{name: name, smbStat: ent.st}
+		fn(st.toGoStat())
+	}
+	return nil
+}
+
+// readDirNames is readDir returning only the names. Must be called with
+// s.mutex held.
+func (s *Smb) readDirNames(dir string) ([]string, error) {
+	infos, err := s.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, nil
+}
@@ -0,0 +1,319 @@
+package libsmb2
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrDecrypt is returned when encrypted content does not authenticate:
+// it was altered, truncated, or sealed with another key.
+var ErrDecrypt = errors.New("encrypted content failed authentication")
+
+// errEncrypted refuses raw file access on a session with encryption set.
+// It matches ErrNotSupported.
+type errEncrypted struct{}
+
+func (errEncrypted) Error() string {
+	return "raw file access bypasses encryption, use OpenReader or Create"
+}
+
+func (errEncrypted) Is(target error) bool {
+	return target == ErrNotSupported
+}
+
+// KeyProvider wraps the per-file data keys of client-side encryption with
+// a key encryption key it holds, such as a KMS or HSM key.
+type KeyProvider interface {
+	// WrapKey seals key and names the key encryption key it used.
+	WrapKey(key []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey recovers a key sealed by WrapKey under keyID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider over local AES keys, 16, 24 or 32 bytes long.
+// New files are sealed under Current; the other keys stay usable for
+// reading, so keys can be rotated.
+type KeyRing struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (k *KeyRing) WrapKey(key []byte) (string, []byte, error) {
+	aead, err := newGCM(k.Keys[k.Current])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.Current, aead.Seal(nonce, nonce, key, []byte(k.Current)), nil
+}
+
+func (k *KeyRing) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(k.Keys[keyID])
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if key == nil {
+		return nil, errors.New("unknown key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SetEncryption encrypts file content on the client for shares that are
+// not trusted with it. Upload, WriteFile, Create, CreateChecked, ReadFile,
+// Download, OpenReader, Store and Bucket objects then seal and open
+// content with a fresh AES-256-GCM key per file, wrapped by kp and stored
+// in the file header.
+//
+// Not covered: names, directories, sizes (those of the sealed files) and
+// timestamps are visible on the share; CopyBetween, CopyTree, Manifest,
+// WalkFingerprints and ETags work on the sealed bytes as they are; the
+// package's own metadata, Mutex leases and resume sidecars, is kept in
+// the clear. Operations on raw bytes that sealing
+// cannot cover fail with ErrNotSupported instead: OpenFile, OpenRange,
+// Prewarm, NewScanner, TailFile, ReplicaSet and Route reads, LogWriter
+// writes and Print. Resumable transfers start over. nil turns encryption
+// off.
+func (s *Smb) SetEncryption(kp KeyProvider) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.encryption = kp
+}
+
+// The sealed format is a header, then chunks of up to encChunk bytes of
+// plaintext, each sealed with the nonce of the header incremented by its
+// index. A flag bound into every chunk marks the last, so dropping
+// trailing chunks is detected.
+//
+//	header: magic | key ID length (2) | key ID | wrapped length (2) | wrapped key | nonce (12)
+//	chunk:  last flag (1) | length (4) | sealed plaintext
+const (
+	encMagic = "SMB2GOE1"
+	encChunk = 64 << 10
+)
+
+type sealWriter struct {
+	w     io.WriteCloser
+	aead  cipher.AEAD
+	nonce []byte
+	index uint64
+	buf   []byte
+	err   error
+}
+
+// newSealWriter writes the header to w and returns a writer sealing into
+// it. Close seals the last chunk and closes w.
+func newSealWriter(w io.WriteCloser, kp KeyProvider) (io.WriteCloser, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := kp.WrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := []byte(encMagic)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	header = append(header, nonce...)
+	if err := writeFull(w, header); err != nil {
+		return nil, err
+	}
+	return &sealWriter{w: w, aead: aead, nonce: nonce, buf: make([]byte, 0, encChunk)}, nil
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 && s.err == nil {
+		if len(s.buf) == encChunk {
+			s.err = s.seal(false)
+		}
+		c := copy(s.buf[len(s.buf):encChunk], p)
+		s.buf = s.buf[:len(s.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, s.err
+}
+
+// Flush seals what is buffered as a chunk of its own, so it reaches the
+// file before the stream ends.
+func (s *sealWriter) Flush() error {
+	if s.err == nil && len(s.buf) > 0 {
+		s.err = s.seal(false)
+	}
+	return s.err
+}
+
+func (s *sealWriter) Close() error {
+	if s.err == nil {
+		s.err = s.seal(true)
+	}
+	if err := s.w.Close(); s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+func (s *sealWriter) seal(last bool) error {
+	flag := []byte{0}
+	if last {
+		flag[0] = 1
+	}
+	sealed := s.aead.Seal(nil, chunkNonce(s.nonce, s.index), s.buf, flag)
+	s.index++
+	s.buf = s.buf[:0]
+	out := append(flag, binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))...)
+	return writeFull(s.w, append(out, sealed...))
+}
+
+// chunkNonce returns base with index added to its last eight bytes.
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := append([]byte(nil), base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)+index)
+	return nonce
+}
+
+type openReader struct {
+	r     *bufio.Reader
+	c     io.Closer
+	aead  cipher.AEAD
+	nonce []byte
+	index uint64
+	plain []byte
+	last  bool
+	err   error
+}
+
+// newOpenReader reads the header from r and returns a reader opening the
+// chunks after it. Close closes r.
+func newOpenReader(r io.ReadCloser, kp KeyProvider) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	field := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, truncated(err)
+		}
+		return b, nil
+	}
+	lenField := func() ([]byte, error) {
+		n, err := field(2)
+		if err != nil {
+			return nil, err
+		}
+		return field(int(binary.BigEndian.Uint16(n)))
+	}
+	magic, err := field(len(encMagic))
+	if err != nil || string(magic) != encMagic {
+		return nil, ErrDecrypt
+	}
+	keyID, err := lenField()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := lenField()
+	if err != nil {
+		return nil, err
+	}
+	key, err := kp.UnwrapKey(string(keyID), wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := field(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return &openReader{r: br, c: r, aead: aead, nonce: nonce}, nil
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 && o.err == nil {
+		o.err = o.next()
+	}
+	if len(o.plain) == 0 {
+		return 0, o.err
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+// next opens the following chunk into o.plain.
+func (o *openReader) next() error {
+	if o.last {
+		if _, err := o.r.ReadByte(); err == nil {
+			return ErrDecrypt
+		}
+		return io.EOF
+	}
+	var head [5]byte
+	if _, err := io.ReadFull(o.r, head[:]); err != nil {
+		return truncated(err)
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if head[0] > 1 || size > encChunk+uint32(o.aead.Overhead()) {
+		return ErrDecrypt
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(o.r, sealed); err != nil {
+		return truncated(err)
+	}
+	plain, err := o.aead.Open(sealed[:0], chunkNonce(o.nonce, o.index), sealed, head[:1])
+	if err != nil {
+		return ErrDecrypt
+	}
+	o.index++
+	o.plain = plain
+	o.last = head[0] == 1
+	return nil
+}
+
+// truncated turns running out of sealed content into ErrDecrypt, leaving
+// read errors of the file itself as they are.
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrDecrypt
+	}
+	return err
+}
+
+func (o *openReader) Close() error {
+	return o.c.Close()
+}
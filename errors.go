@@ -0,0 +1,98 @@
+package libsmb2
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+// ErrClosed is returned for operations on a disconnected session or a
+// closed file.
+var ErrClosed = errors.New("session closed")
+
+// ErrNotSupported is returned by wrappers for features the linked libsmb2
+// does not provide.
+var ErrNotSupported = errors.New("not supported by the linked libsmb2")
+
+// Error records a failed operation together with where it happened.
+// The cause is an NTStatus when the server reported one, a syscall.Errno
+// when libsmb2 only returned an errno, or one of the package sentinels,
+// so errors.Is(err, os.ErrNotExist) and friends work as expected.
+type Error struct {
+	Op    string
+	Host  string
+	Share string
+	Path  string
+	// Msg is the libsmb2 error text, if any.
+	Msg string
+	// Access is the access the operation requested, set when the server
+	// denied it.
+	Access AccessMask
+	Err    error
+}
+
+func (e *Error) Error() string {
+	where := "//" + e.Host + "/" + e.Share
+	if e.Path != "" {
+		where += "/" + e.Path
+	}
+	detail := e.Msg
+	if detail == "" && e.Err != nil {
+		detail = e.Err.Error()
+	}
+	if e.Access != 0 {
+		detail += " (requested access " + e.Access.String() + ")"
+	}
+	return e.Op + " " + where + ": " + detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ntStatusInMsg matches the status libsmb2 embeds in its error text, as in
+// "Open failed with (0xc0000034) STATUS_OBJECT_NAME_NOT_FOUND".
+var ntStatusInMsg = regexp.MustCompile(`\(0x([0-9a-fA-F]{8})\)`)
+
+// errorCause picks the most precise cause for a failed libsmb2 call that
+// returned code and left msg behind.
+func errorCause(code int, msg string) error {
+	if m := ntStatusInMsg.FindStringSubmatch(msg); m != nil {
+		if status, err := strconv.ParseUint(m[1], 16, 32); err == nil {
+			return NTStatus(status)
+		}
+	}
+	if code == -int(syscall.ETIMEDOUT) {
+		return os.ErrDeadlineExceeded
+	}
+	if code < 0 {
+		return syscall.Errno(-code)
+	}
+	return errors.New(msg)
+}
+
+// newError builds the error for op on path from a libsmb2 return code and
+// the captured error text.
+func (s *Smb) newError(op string, path string, code int, msg string) error {
+	return withAccess(&Error{
+		Op:    op,
+		Host:  s.host,
+		Share: s.share,
+		Path:  path,
+		Msg:   msg,
+		Err:   errorCause(code, msg),
+	}, opAccess[op])
+}
+
+// wrapError attaches the session context to err.
+func (s *Smb) wrapError(op string, path string, err error) error {
+	return &Error{
+		Op:    op,
+		Host:  s.host,
+		Share: s.share,
+		Path:  path,
+		Err:   err,
+	}
+}
@@ -0,0 +1,65 @@
+package libsmb2
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag for a file stat'ed on a share, made of
+// its file ID, change time and size. It changes with any write, rename
+// over or metadata change, and stays the same across sessions and
+// reconnects. Without server file IDs it falls back on the modification
+// time.
+func ETag(info os.FileInfo) string {
+	if st, ok := info.Sys().(*FileStat); ok && st != nil && st.Ino != 0 {
+		return fmt.Sprintf(`"%x-%x-%x"`, st.Ino, st.ChangeTime.UnixNano(), info.Size())
+	}
+	return fmt.Sprintf(`"m%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// SetValidators sets the ETag and Last-Modified headers of a response
+// serving info.
+func SetValidators(w http.ResponseWriter, info os.FileInfo) {
+	w.Header().Set("ETag", ETag(info))
+	if mod := info.ModTime(); !mod.IsZero() {
+		w.Header().Set("Last-Modified", mod.UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified reports whether a GET or HEAD request for info can be
+// answered with 304 Not Modified. If-None-Match is checked first and, when
+// present, decides alone; If-Modified-Since is compared at the second
+// granularity of HTTP dates.
+func NotModified(r *http.Request, info os.FileInfo) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, ETag(info))
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	mod := info.ModTime()
+	if ims == "" || mod.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !mod.Truncate(time.Second).After(t)
+}
+
+// etagMatch applies the weak comparison of If-None-Match to a list of
+// tags.
+func etagMatch(list string, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
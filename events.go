@@ -0,0 +1,60 @@
+package libsmb2
+
+import "encoding/json"
+
+// EventKind says what a TransferEvent reports.
+type EventKind int
+
+const (
+	FileStarted EventKind = iota
+	FileProgress
+	FileDone
+	FileFailed
+)
+
+var eventKindNames = [...]string{
+	FileStarted:  "started",
+	FileProgress: "progress",
+	FileDone:     "done",
+	FileFailed:   "failed",
+}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return "unknown"
+}
+
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// TransferEvent is emitted by the copy helpers through CopyOptions.Events.
+// Bytes is the count copied so far and Size the source size; Err is set on
+// FileFailed only.
+type TransferEvent struct {
+	Kind  EventKind
+	Src   string
+	Dst   string
+	Bytes int64
+	Size  int64
+	Err   error
+}
+
+// MarshalJSON encodes the event with its error as a string, for reports
+// meant to be read by other tools.
+func (e TransferEvent) MarshalJSON() ([]byte, error) {
+	var msg string
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Kind  EventKind `json:"kind"`
+		Src   string    `json:"src"`
+		Dst   string    `json:"dst"`
+		Bytes int64     `json:"bytes"`
+		Size  int64     `json:"size"`
+		Err   string    `json:"error,omitempty"`
+	}{e.Kind, e.Src, e.Dst, e.Bytes, e.Size, msg})
+}
@@ -0,0 +1,62 @@
+//go:build libsmb2_faults
+// +build libsmb2_faults
+
+package libsmb2
+
+import "time"
+
+// Fault describes a failure to inject into a single operation.
+type Fault struct {
+	// Latency is slept before the operation is executed. The session stays
+	// locked meanwhile, as it does during a slow request, so its other
+	// calls wait too.
+	Latency time.Duration
+	// Disconnect tears down the session before the operation, as if the
+	// server had dropped the connection.
+	Disconnect bool
+	// Status fails the operation with the given NT status when non-zero.
+	Status NTStatus
+}
+
+// FaultFunc decides which fault, if any, to inject into op on path.
+// Operations are named "connect", "open", "stat", "read", "write", "seek"
+// and "readdir". It is called with the session locked and must not call
+// methods of the session.
+type FaultFunc func(op string, path string) Fault
+
+type faultState struct {
+	fn  FaultFunc
+	wan *WANProfile
+}
+
+// SetFaultInjector installs fn to be consulted before every operation on
+// the session. A nil fn disables injection. Only available when built with
+// the libsmb2_faults tag.
+func (s *Smb) SetFaultInjector(fn FaultFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults.fn = fn
+}
+
+// injectFault applies the configured fault and simulated network delay for
+// op moving size bytes. Must be called with s.mutex held.
+func (s *Smb) injectFault(op string, path string, size int) error {
+	if s.faults.wan != nil {
+		time.Sleep(s.faults.wan.delay(size))
+	}
+	if s.faults.fn == nil {
+		return nil
+	}
+	fault := s.faults.fn(op, path)
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	if fault.Disconnect {
+		s.disconnect()
+		return StatusConnectionDisconnected
+	}
+	if fault.Status != StatusSuccess {
+		return fault.Status
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package libsmb2
+
+import (
+	"errors"
+	"os"
+	path2 "path"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+//#include <stdlib.h>
+//#include "libsmb2go.h"
+import "C"
+
+// Mkdir creates the directory path.
+func (s *Smb) Mkdir(path string) error {
+	return s.invoke("mkdir", path, 0, func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.mkdir(path)
+	})
+}
+
+// MkdirAll creates path and any missing parents. It is not an error if
+// path already exists as a directory.
+func (s *Smb) MkdirAll(path string) error {
+	return s.invoke("mkdir", path, 0, func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.mkdirAll(path)
+	})
+}
+
+// Remove removes a file or an empty directory, or moves it to the recycle
+// directory if one is set.
+func (s *Smb) Remove(path string) error {
+	return s.invoke("remove", path, 0, func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.recycles(path) {
+			return s.recycle(path, false)
+		}
+		return s.remove(path)
+	})
+}
+
+// RemoveAll removes path and everything below it, or moves it to the
+// recycle directory if one is set. It is not an error if path does not
+// exist.
+func (s *Smb) RemoveAll(path string) error {
+	return s.invoke("remove", path, 0, func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.recycles(path) {
+			return s.recycle(path, true)
+		}
+		return s.removeAll(path)
+	})
+}
+
+// Rename renames oldpath to newpath within the share.
+func (s *Smb) Rename(oldpath string, newpath string) error {
+	return s.invoke("rename", oldpath, 0, func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.rename(oldpath, newpath)
+	})
+}
+
+// pathOp runs a libsmb2 call that takes a single path. Must be called with
+// s.mutex held.
+func (s *Smb) pathOp(op string, path string, call func(cPath *C.char, cerr *cError) C.int) error {
+	if s.session == nil {
+		return s.wrapError(op, path, ErrClosed)
+	}
+	defer s.logSlow(op, path, 0, time.Now())
+	if err := s.injectFault(op, path, 0); err != nil {
+		return s.wrapError(op, path, err)
+	}
+	cPath, err := s.cPath(op, path)
+	if err != nil {
+		return err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	var cerr cError
+	if code := call(cPath, &cerr); code < 0 {
+		return s.newError(op, path, int(code), cerr.String())
+	}
+	return nil
+}
+
+func (s *Smb) mkdir(path string) error {
+	path, err := s.createName("mkdir", path, os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	return s.pathOp("mkdir", path, func(cPath *C.char, cerr *cError) C.int {
+		return C.smb2_mkdir_wrapper(s.session, cPath, cerr.ptr(), cerr.len())
+	})
+}
+
+func (s *Smb) mkdirAll(path string) error {
+	path = strings.Trim(path, "/")
+	if path == "" || path == "." {
+		return nil
+	}
+	if fi, err := s.stat(path); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return s.wrapError("mkdir", path, StatusNotADirectory)
+	}
+	if err := s.mkdirAll(path2.Dir(path)); err != nil {
+		return err
+	}
+	if err := s.mkdir(path); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Smb) remove(path string) error {
+	fi, err := s.stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return s.pathOp("rmdir", path, func(cPath *C.char, cerr *cError) C.int {
+			return C.smb2_rmdir_wrapper(s.session, cPath, cerr.ptr(), cerr.len())
+		})
+	}
+	return s.pathOp("unlink", path, func(cPath *C.char, cerr *cError) C.int {
+		return C.smb2_unlink_wrapper(s.session, cPath, cerr.ptr(), cerr.len())
+	})
+}
+
+func (s *Smb) removeAll(path string) error {
+	fi, err := s.stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		names, err := s.readDirNames(path)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := s.removeAll(path2.Join(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return s.remove(path)
+}
+
+func (s *Smb) rename(oldpath string, newpath string) error {
+	if s.session == nil {
+		return s.wrapError("rename", oldpath, ErrClosed)
+	}
+	defer s.logSlow("rename", oldpath, 0, time.Now())
+	if err := s.injectFault("rename", oldpath, 0); err != nil {
+		return s.wrapError("rename", oldpath, err)
+	}
+	newpath, err := s.createName("rename", newpath, os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	cOld, err := s.cPath("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	defer C.free(unsafe.Pointer(cOld))
+	cNew, err := s.cPath("rename", newpath)
+	if err != nil {
+		return err
+	}
+	defer C.free(unsafe.Pointer(cNew))
+	var cerr cError
+	if code := C.smb2_rename_wrapper(s.session, cOld, cNew, cerr.ptr(), cerr.len()); code < 0 {
+		return s.newError("rename", oldpath, int(code), cerr.String())
+	}
+	return nil
+}
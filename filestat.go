@@ -0,0 +1,54 @@
+package libsmb2
+
+import "time"
+
+//#include "libsmb2go.h"
+import "C"
+
+const (
+	// filetimeEpochDelta is the number of seconds from the FILETIME epoch,
+	// 1601-01-01, to the Unix epoch.
+	filetimeEpochDelta = 11644473600
+	// maxFiletimeUnix is the largest valid FILETIME (0x7fffffffffffffff)
+	// in Unix seconds. Larger values come from the all-ones "not set"
+	// sentinel.
+	maxFiletimeUnix = (1<<63-1)/10000000 - filetimeEpochDelta
+)
+
+// FileStat is the underlying data source returned by FileInfo.Sys().
+// All times are UTC. A zero time means the server left the timestamp
+// unset (FILETIME 0 or the all-ones sentinel).
+type FileStat struct {
+	// Ino is the server's file id.
+	Ino   uint64
+	Nlink uint32
+	// Type is the libsmb2 entry type: file, directory or link.
+	Type       uint32
+	AccessTime time.Time
+	ModTime    time.Time
+	ChangeTime time.Time
+	BirthTime  time.Time
+}
+
+// smbTime converts a libsmb2 timestamp to a UTC time.Time. libsmb2 has
+// already translated the FILETIME to Unix seconds, so its sentinels arrive
+// as out of range seconds. Sub-second precision is whatever libsmb2 kept.
+func smbTime(sec C.uint64_t, nsec C.uint64_t) time.Time {
+	s := int64(sec)
+	if s <= -filetimeEpochDelta || s > maxFiletimeUnix {
+		return time.Time{}
+	}
+	return time.Unix(s, int64(nsec)).UTC()
+}
+
+func newFileStat(st *C.struct_smb2_stat_64) *FileStat {
+	return &FileStat{
+		Ino:        uint64(st.smb2_ino),
+		Nlink:      uint32(st.smb2_nlink),
+		Type:       uint32(st.smb2_type),
+		AccessTime: smbTime(st.smb2_atime, st.smb2_atime_nsec),
+		ModTime:    smbTime(st.smb2_mtime, st.smb2_mtime_nsec),
+		ChangeTime: smbTime(st.smb2_ctime, st.smb2_ctime_nsec),
+		BirthTime:  smbTime(st.smb2_btime, st.smb2_btime_nsec),
+	}
+}
@@ -0,0 +1,179 @@
+package libsmb2
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter selects entries with gitignore style patterns, for Walk and the
+// bulk helpers built on it.
+//
+// Exclude patterns follow .gitignore: "*" and "?" stay within a path
+// component, "**" spans components, a trailing "/" only matches
+// directories, a pattern with an inner or leading "/" is anchored to the
+// walk root while one without matches at any depth, "!" re-includes what an
+// earlier pattern excluded, and the last matching pattern wins. Excluding a
+// directory excludes everything below it. Include patterns use the same
+// syntax; when any are given, only files matching one of them are
+// selected, while directories are still descended into.
+type Filter struct {
+	include []filterPattern
+	exclude []filterPattern
+}
+
+type filterPattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewFilter compiles the include and exclude patterns. Blank patterns and
+// patterns starting with "#" are ignored.
+func NewFilter(include []string, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.include, err = compilePatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match reports whether the slash separated path rel, relative to the walk
+// root, is selected.
+func (f *Filter) Match(rel string, isDir bool) bool {
+	excluded := false
+	for _, p := range f.exclude {
+		if p.match(rel, isDir) {
+			excluded = !p.negate
+		}
+	}
+	if excluded {
+		return false
+	}
+	if isDir || len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if !p.negate && p.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// WalkFunc wraps fn for a walk starting at root so that it only sees
+// selected entries. Excluded directories are skipped entirely.
+func (f *Filter) WalkFunc(root string, fn WalkFunc) WalkFunc {
+	return f.walkFunc(root, fn, nil)
+}
+
+// walkFunc is WalkFunc telling skipped, if not nil, about every entry it
+// leaves out.
+func (f *Filter) walkFunc(root string, fn WalkFunc, skipped func(path string)) WalkFunc {
+	root = strings.Trim(root, "/")
+	return func(path string, info os.FileInfo, err error) error {
+		rel := strings.Trim(strings.TrimPrefix(strings.Trim(path, "/"), root), "/")
+		if rel == "" || info == nil {
+			return fn(path, info, err)
+		}
+		if !f.Match(rel, info.IsDir()) {
+			if skipped != nil {
+				skipped(path)
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, info, err)
+	}
+}
+
+func (p *filterPattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(rel)
+}
+
+func compilePatterns(patterns []string) ([]filterPattern, error) {
+	var compiled []filterPattern
+	for _, pattern := range patterns {
+		p, ok, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			compiled = append(compiled, p)
+		}
+	}
+	return compiled, nil
+}
+
+func compilePattern(pattern string) (filterPattern, bool, error) {
+	var p filterPattern
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return p, false, nil
+	}
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var re strings.Builder
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			re.WriteString(regexp.QuoteMeta(string(r)))
+			i += size - 1
+		}
+	}
+	re.WriteString("$")
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return p, false, err
+	}
+	p.re = compiled
+	return p, true, nil
+}
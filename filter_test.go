@@ -0,0 +1,37 @@
+package libsmb2
+
+import "testing"
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		{"no patterns", nil, nil, "a/b.txt", false, true},
+		{"excluded extension", nil, []string{"*.tmp"}, "a/b.tmp", false, false},
+		{"star does not cross a slash", nil, []string{"/a*"}, "ab/c", false, true},
+		{"anchored", nil, []string{"/build"}, "src/build", true, true},
+		{"double star", nil, []string{"logs/**"}, "logs/2024/01.log", false, false},
+		{"dir only", nil, []string{"cache/"}, "cache", false, true},
+		{"re-included", nil, []string{"*.log", "!keep.log"}, "keep.log", false, true},
+		{"include miss", []string{"*.go"}, nil, "README", false, false},
+		{"accented name", nil, []string{"résumé*"}, "docs/résumé-2024.pdf", false, false},
+		{"accented miss", nil, []string{"résumé*"}, "docs/resume.pdf", false, true},
+		{"question mark on rune", nil, []string{"caf?"}, "café", false, false},
+		{"cjk directory", nil, []string{"日本/**"}, "日本/東京/a.txt", false, false},
+		{"cjk include", []string{"*.日本語"}, nil, "x/文書.日本語", false, true},
+	}
+	for _, tt := range tests {
+		f, err := NewFilter(tt.include, tt.exclude)
+		if err != nil {
+			t.Fatalf("%s: NewFilter() = %v", tt.name, err)
+		}
+		if got := f.Match(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("%s: Match(%q) = %v, want %v", tt.name, tt.rel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package libsmb2
+
+import (
+	"errors"
+	"io"
+	"os"
+	path2 "path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FingerprintOptions tunes WalkFingerprints.
+type FingerprintOptions struct {
+	// Fingerprint computes the fingerprint of the file at path from its
+	// content, a hash or a similarity sketch. Required.
+	Fingerprint func(path string, r io.Reader) ([]byte, error)
+	// Concurrency is how many files are fingerprinted at once, default 4.
+	Concurrency int
+	// BytesPerSecond caps the reading of all of them together, 0 is
+	// unlimited.
+	BytesPerSecond int64
+	// MaxSize leaves files larger than this without a fingerprint, 0
+	// fingerprints everything.
+	MaxSize int64
+	// Sessions are extra sessions connected to the same share to read
+	// on; files are spread over them and the walking session.
+	Sessions []*Smb
+}
+
+// FingerprintFunc is called by WalkFingerprints with an entry of the walk
+// and the fingerprint of its content, nil for directories and skipped
+// files. err is a failure to list the directory or to fingerprint the
+// file; a failing file does not end the walk unless fn says so.
+type FingerprintFunc func(path string, info os.FileInfo, fp []byte, err error) error
+
+// errStopped ends the producing walk once the visitor has returned.
+var errStopped = errors.New("walk stopped")
+
+type fingerprintItem struct {
+	path string
+	info os.FileInfo
+	fp   []byte
+	err  error
+	done chan struct{}
+}
+
+// WalkFingerprints walks the tree at root like Walk, fingerprinting files
+// ahead of fn in the background, and calls fn for every entry in Walk's
+// order. filepath.SkipDir skips what is below a directory, or the rest of
+// a file's parent, as with Walk, though some of it may already have been
+// read; what is still pending below it is neither listed nor read. Any
+// other error stops the walk. At most twice Concurrency entries are held
+// ahead of fn, and none is still being read once WalkFingerprints returns.
+func (s *Smb) WalkFingerprints(root string, opts *FingerprintOptions, fn FingerprintFunc) error {
+	if opts == nil || opts.Fingerprint == nil {
+		return errors.New("walk fingerprints needs a fingerprint function")
+	}
+	n := opts.Concurrency
+	if n <= 0 {
+		n = 4
+	}
+	sessions := append([]*Smb{s}, opts.Sessions...)
+	budget := newBucket(float64(opts.BytesPerSecond))
+	slots := make(chan struct{}, n)
+	items := make(chan *fingerprintItem, 2*n)
+	stop := make(chan struct{})
+	// skip is the directory the visitor skipped, shared with the producer
+	// so none of it is listed or read any further.
+	var mutex sync.Mutex
+	var skip string
+	skipped := func(p string) bool {
+		select {
+		case <-stop:
+			return true
+		default:
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		return skip != "" && strings.HasPrefix(p+"/", skip)
+	}
+	var pending sync.WaitGroup
+	var walkErr error
+	go func() {
+		defer close(items)
+		files := 0
+		walkErr = s.Walk(root, func(p string, info os.FileInfo, err error) error {
+			select {
+			case <-stop:
+				return errStopped
+			default:
+			}
+			if skipped(p) {
+				return filepath.SkipDir
+			}
+			item := &fingerprintItem{path: p, info: info, err: err, done: make(chan struct{})}
+			if err != nil || info.IsDir() || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+				close(item.done)
+			} else {
+				from := sessions[files%len(sessions)]
+				files++
+				pending.Add(1)
+				go func() {
+					defer pending.Done()
+					defer close(item.done)
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					if !skipped(item.path) {
+						item.fp, item.err = fingerprintFile(from, item.path, budget, opts.Fingerprint)
+					}
+				}()
+			}
+			select {
+			case items <- item:
+				return nil
+			case <-stop:
+				return errStopped
+			}
+		})
+	}()
+
+	var err error
+	for item := range items {
+		<-item.done
+		if skipped(item.path) {
+			continue
+		}
+		err = fn(item.path, item.info, item.fp, item.err)
+		if err == filepath.SkipDir && item.info != nil {
+			dir := path2.Dir(item.path)
+			if item.info.IsDir() {
+				dir = path2.Clean(item.path)
+			}
+			if dir == "." || dir == path2.Clean(root) {
+				// That is all there is below root.
+				break
+			}
+			mutex.Lock()
+			skip, err = dir+"/", nil
+			mutex.Unlock()
+			continue
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(stop)
+	for range items {
+	}
+	pending.Wait()
+	if err == filepath.SkipDir {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if walkErr == errStopped {
+		return nil
+	}
+	return walkErr
+}
+
+func fingerprintFile(s *Smb, path string, budget *bucket, fp func(string, io.Reader) ([]byte, error)) ([]byte, error) {
+	f, err := s.open(path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return fp(path, budgetReader{r: f, budget: budget})
+}
+
+// budgetReader paces reads to a shared token bucket.
+type budgetReader struct {
+	r      io.Reader
+	budget *bucket
+}
+
+func (r budgetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if d := r.budget.take(float64(n)); d > 0 {
+		time.Sleep(d)
+	}
+	return n, err
+}
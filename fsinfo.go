@@ -0,0 +1,102 @@
+package libsmb2
+
+import "unsafe"
+
+//#include <stdlib.h>
+//#include "libsmb2go.h"
+import "C"
+
+// maxReadlink bounds the target returned by Readlink.
+const maxReadlink = 64 * 1024
+
+// StatVFS describes the capacity of a share.
+type StatVFS struct {
+	BlockSize       uint32
+	FragmentSize    uint32
+	Blocks          uint64
+	BlocksFree      uint64
+	BlocksAvailable uint64
+	Files           uint32
+	FilesFree       uint32
+	NameMax         uint32
+}
+
+// Total returns the capacity of the share in bytes.
+func (st *StatVFS) Total() uint64 {
+	return st.Blocks * uint64(st.FragmentSize)
+}
+
+// Available returns the bytes available to the connected user.
+func (st *StatVFS) Available() uint64 {
+	return st.BlocksAvailable * uint64(st.FragmentSize)
+}
+
+// Statvfs reports capacity and free space of the share holding path.
+func (s *Smb) Statvfs(path string) (st *StatVFS, err error) {
+	err = s.invoke("statvfs", path, 0, func() (err error) {
+		st, err = s.statvfs(path)
+		return
+	})
+	return
+}
+
+func (s *Smb) statvfs(path string) (*StatVFS, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session == nil {
+		return nil, s.wrapError("statvfs", path, ErrClosed)
+	}
+	cPath, err := s.cPath("statvfs", path)
+	if err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	var st C.struct_smb2_statvfs
+	var cerr cError
+	if code := C.libsmb2go_statvfs(s.session, cPath, &st, cerr.ptr(), cerr.len()); code == -C.ENOTSUP {
+		return nil, s.wrapError("statvfs", path, ErrNotSupported)
+	} else if code < 0 {
+		return nil, s.newError("statvfs", path, int(code), cerr.String())
+	}
+	return &StatVFS{
+		BlockSize:       uint32(st.f_bsize),
+		FragmentSize:    uint32(st.f_frsize),
+		Blocks:          uint64(st.f_blocks),
+		BlocksFree:      uint64(st.f_bfree),
+		BlocksAvailable: uint64(st.f_bavail),
+		Files:           uint32(st.f_files),
+		FilesFree:       uint32(st.f_ffree),
+		NameMax:         uint32(st.f_namemax),
+	}, nil
+}
+
+// Readlink returns the target of the symbolic link at path.
+func (s *Smb) Readlink(path string) (target string, err error) {
+	err = s.invoke("readlink", path, 0, func() (err error) {
+		target, err = s.readlink(path)
+		return
+	})
+	return
+}
+
+func (s *Smb) readlink(path string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session == nil {
+		return "", s.wrapError("readlink", path, ErrClosed)
+	}
+	cPath, err := s.cPath("readlink", path)
+	if err != nil {
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	buf := (*C.char)(C.calloc(1, maxReadlink))
+	defer C.free(unsafe.Pointer(buf))
+	var cerr cError
+	if code := C.libsmb2go_readlink(s.session, cPath, buf, maxReadlink-1, cerr.ptr(), cerr.len()); code == -C.ENOTSUP {
+		return "", s.wrapError("readlink", path, ErrNotSupported)
+	} else if code < 0 {
+		return "", s.newError("readlink", path, int(code), cerr.String())
+	}
+	return C.GoString(buf), nil
+}
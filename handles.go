@@ -0,0 +1,40 @@
+package libsmb2
+
+import (
+	"sort"
+	"time"
+)
+
+// Handle describes a file or directory held open on a session.
+type Handle struct {
+	Path         string
+	Flags        int
+	Dir          bool
+	Opened       time.Time
+	Age          time.Duration
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// OpenHandles lists the files and directories opened on the session and
+// not closed yet, oldest first, to track down leaks in long-running
+// programs.
+func (s *Smb) OpenHandles() []Handle {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	handles := make([]Handle, 0, len(s.files))
+	for f := range s.files {
+		handles = append(handles, Handle{
+			Path:         f.path,
+			Flags:        f.flags,
+			Dir:          f.isDir,
+			Opened:       f.opened,
+			Age:          now.Sub(f.opened),
+			BytesRead:    f.bytesRead,
+			BytesWritten: f.bytesWritten,
+		})
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i].Opened.Before(handles[j].Opened) })
+	return handles
+}
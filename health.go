@@ -0,0 +1,97 @@
+package libsmb2
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+//#include "libsmb2go.h"
+import "C"
+
+// Health is the result of HealthCheck, shaped for readiness endpoints.
+// A probe that was not run has zero duration and no error.
+type Health struct {
+	Healthy   bool
+	Echo      time.Duration
+	EchoErr   error
+	ProbePath string
+	Probe     time.Duration
+	ProbeErr  error
+}
+
+// MarshalJSON encodes the health with errors as strings.
+func (h Health) MarshalJSON() ([]byte, error) {
+	msg := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	return json.Marshal(struct {
+		Healthy   bool          `json:"healthy"`
+		Echo      time.Duration `json:"echo_ns"`
+		EchoErr   string        `json:"echo_error,omitempty"`
+		ProbePath string        `json:"probe_path,omitempty"`
+		Probe     time.Duration `json:"probe_ns,omitempty"`
+		ProbeErr  string        `json:"probe_error,omitempty"`
+	}{h.Healthy, h.Echo, msg(h.EchoErr), h.ProbePath, h.Probe, msg(h.ProbeErr)})
+}
+
+// SetHealthProbe sets the path HealthCheck stats after the echo, "" for
+// none. A small, always present file or directory checks that the share
+// itself still answers, not only the server.
+func (s *Smb) SetHealthProbe(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.healthProbe = path
+}
+
+// HealthCheck sends an SMB2 ECHO and, if a probe path is set, stats it.
+// libsmb2 calls cannot be interrupted, so when ctx ends first the check
+// is reported failed with ctx's error while the calls finish in the
+// background.
+func (s *Smb) HealthCheck(ctx context.Context) Health {
+	done := make(chan Health, 1)
+	go func() { done <- s.healthCheck() }()
+	select {
+	case h := <-done:
+		return h
+	case <-ctx.Done():
+		return Health{EchoErr: ctx.Err()}
+	}
+}
+
+func (s *Smb) healthCheck() Health {
+	var h Health
+	start := time.Now()
+	h.EchoErr = s.invoke("echo", "", 0, s.echo)
+	h.Echo = time.Since(start)
+	s.mutex.Lock()
+	h.ProbePath = s.healthProbe
+	s.mutex.Unlock()
+	if h.EchoErr == nil && h.ProbePath != "" {
+		start = time.Now()
+		_, h.ProbeErr = s.Stat(h.ProbePath)
+		h.Probe = time.Since(start)
+	}
+	h.Healthy = h.EchoErr == nil && h.ProbeErr == nil
+	return h
+}
+
+func (s *Smb) echo() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session == nil || !s.connected {
+		return s.wrapError("echo", "", ErrClosed)
+	}
+	defer s.logSlow("echo", "", 0, time.Now())
+	if err := s.injectFault("echo", "", 0); err != nil {
+		return s.wrapError("echo", "", err)
+	}
+	var cerr cError
+	if code := C.smb2_echo_wrapper(s.session, cerr.ptr(), cerr.len()); code < 0 {
+		return s.newError("echo", "", int(code), cerr.String())
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package libsmb2
+
+import (
+	"os"
+	"strings"
+)
+
+// HiddenFunc reports whether a directory entry should be left out of
+// listings.
+type HiddenFunc func(name string) bool
+
+// hiddenNames are the system and housekeeping files Windows and macOS
+// clients leave on shares.
+var hiddenNames = map[string]bool{
+	"desktop.ini":               true,
+	"thumbs.db":                 true,
+	"ehthumbs.db":               true,
+	".ds_store":                 true,
+	"$recycle.bin":              true,
+	"system volume information": true,
+}
+
+// DefaultHidden hides Office lock files (~$name), desktop.ini, Thumbs.db,
+// .DS_Store, AppleDouble files (._name), the recycle bin and System
+// Volume Information. The package cannot read the hidden and system
+// attributes, which libsmb2 does not return, so the choice is by name.
+func DefaultHidden(name string) bool {
+	return strings.HasPrefix(name, "~$") ||
+		strings.HasPrefix(name, "._") ||
+		hiddenNames[strings.ToLower(name)]
+}
+
+// SetHidden leaves entries matched by fn out of Readdir, Walk,
+// WalkPrefetch, WalkSeq, Entries and ReadDirPage; a hidden directory is
+// not descended into. nil shows everything. Removing, moving and
+// versioning still see all entries, and ReadDir can bypass the filter
+// per call.
+func (s *Smb) SetHidden(fn HiddenFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hidden = fn
+}
+
+// ReadDir lists dir sorted by name, without "." and "..". Hidden entries
+// are left out unless all is set.
+func (s *Smb) ReadDir(dir string, all bool) (infos []os.FileInfo, err error) {
+	err = s.invoke("readdir", dir, 0, func() (err error) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if all {
+			infos, err = s.readDir(dir)
+		} else {
+			infos, err = s.listDir(dir)
+		}
+		return
+	})
+	return
+}
+
+// listDir is readDir without hidden entries. Must be called with s.mutex
+// held.
+func (s *Smb) listDir(dir string) ([]os.FileInfo, error) {
+	infos, err := s.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.visible(infos), nil
+}
+
+// visible filters hidden entries out of infos in place. Must be called
+// with s.mutex held.
+func (s *Smb) visible(infos []os.FileInfo) []os.FileInfo {
+	if s.hidden == nil {
+		return infos
+	}
+	kept := infos[:0]
+	for _, info := range infos {
+		if !s.hidden(info.Name()) {
+			kept = append(kept, info)
+		}
+	}
+	return kept
+}
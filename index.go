@@ -0,0 +1,124 @@
+package libsmb2
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Index is a cache on local disk of what earlier scans learned about the
+// files of shares, so that repeat scans need not read every file again.
+// A cached digest is reused while the file keeps its file ID, size,
+// modification and change time; the change time moves with any write
+// through any client, so an unchanged one vouches for the content. The
+// listing itself still has to be read, since SMB has no cheaper way to
+// learn that a directory is unchanged, but it costs one request per
+// directory where hashing costs the whole content. One Index may serve
+// sessions to several shares. All its entries are held in memory.
+type Index struct {
+	path    string
+	mutex   sync.Mutex
+	entries map[string]indexEntry
+}
+
+type indexEntry struct {
+	Ino    uint64    `json:"ino"`
+	Size   int64     `json:"size"`
+	Mod    time.Time `json:"mtime"`
+	Change time.Time `json:"ctime"`
+	Hash   string    `json:"hash"`
+	Sum    string    `json:"sum"`
+}
+
+// OpenIndex loads the index kept in the local file path. A missing file
+// is an empty index.
+func OpenIndex(path string) (*Index, error) {
+	ix := &Index{path: path, entries: make(map[string]indexEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ix.entries); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Save writes the index back to its file, replacing it atomically.
+func (ix *Index) Save() error {
+	ix.mutex.Lock()
+	data, err := json.Marshal(ix.entries)
+	ix.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := ix.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ix.path)
+}
+
+// Len returns the number of files in the index.
+func (ix *Index) Len() int {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	return len(ix.entries)
+}
+
+// ManifestIndexed is Manifest taking the digests of unchanged files from
+// ix instead of reading them, and recording new ones in it. Files under
+// root that are gone are dropped from ix. ix is only changed in memory;
+// call Save to keep it. Without a hash it is a plain Manifest.
+func (s *Smb) ManifestIndexed(root string, hashName string, ix *Index) (*Manifest, error) {
+	prefix := strings.TrimSuffix(s.indexKey(root), "/")
+	seen := make(map[string]bool)
+	m, err := s.manifest(root, hashName, func(p string, info os.FileInfo) (string, error) {
+		key := s.indexKey(p)
+		seen[key] = true
+		st, _ := info.Sys().(*FileStat)
+		if st == nil {
+			st = &FileStat{}
+		}
+		ix.mutex.Lock()
+		e, ok := ix.entries[key]
+		ix.mutex.Unlock()
+		if ok && e.Hash == hashName && e.Ino == st.Ino && e.Size == info.Size() &&
+			e.Mod.Equal(info.ModTime()) && e.Change.Equal(st.ChangeTime) && !st.ChangeTime.IsZero() {
+			return e.Sum, nil
+		}
+		sum, err := s.fileSum(p, manifestHashes[hashName]())
+		if err != nil {
+			return "", err
+		}
+		ix.mutex.Lock()
+		ix.entries[key] = indexEntry{Ino: st.Ino, Size: info.Size(), Mod: info.ModTime(), Change: st.ChangeTime, Hash: hashName, Sum: sum}
+		ix.mutex.Unlock()
+		return sum, nil
+	})
+	if err != nil || hashName == "" {
+		return m, err
+	}
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	for key := range ix.entries {
+		if strings.HasPrefix(key, prefix+"/") && !seen[key] {
+			delete(ix.entries, key)
+		}
+	}
+	return m, nil
+}
+
+// indexKey names path on the session's share in an Index.
+func (s *Smb) indexKey(path string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.host + "/" + s.share + "/" + strings.Trim(path, "/")
+}
@@ -0,0 +1,87 @@
+package libsmb2
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrAmbiguousPath is returned by the case-insensitive lookups when a
+// component matches several entries that differ only in case.
+var ErrAmbiguousPath = errors.New("path matches several entries that differ only in case")
+
+// ResolveInsensitive returns the path as stored on the share for a path
+// whose components may differ in case, for shares exported case sensitive
+// (Samba's "case sensitive = yes"). Exact matches win; a component without
+// an exact match must match exactly one entry case-insensitively.
+func (s *Smb) ResolveInsensitive(path string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.resolveInsensitive(path)
+}
+
+// StatInsensitive is Stat with case-insensitive path resolution.
+func (s *Smb) StatInsensitive(path string) (os.FileInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	resolved, err := s.resolveInsensitive(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.stat(resolved)
+}
+
+// OpenFileInsensitive is OpenFile with case-insensitive path resolution.
+func (s *Smb) OpenFileInsensitive(path string, mode int) (*smbFile, error) {
+	resolved, err := s.ResolveInsensitive(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.OpenFile(resolved, mode)
+}
+
+// resolveInsensitive must be called with s.mutex held.
+func (s *Smb) resolveInsensitive(path string) (string, error) {
+	if _, err := s.stat(path); err == nil || !errors.Is(err, os.ErrNotExist) {
+		return path, err
+	}
+	resolved := ""
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		names, err := s.readDirNames(resolved)
+		if err != nil {
+			return "", err
+		}
+		match, err := matchInsensitive(names, part)
+		if err != nil {
+			return "", s.wrapError("resolve", path, err)
+		}
+		if resolved == "" {
+			resolved = match
+		} else {
+			resolved += "/" + match
+		}
+	}
+	return resolved, nil
+}
+
+func matchInsensitive(names []string, part string) (string, error) {
+	var matches []string
+	for _, name := range names {
+		if name == part {
+			return name, nil
+		}
+		if strings.EqualFold(name, part) {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", os.ErrNotExist
+	case 1:
+		return matches[0], nil
+	}
+	return "", ErrAmbiguousPath
+}
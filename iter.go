@@ -0,0 +1,57 @@
+//go:build go1.23
+
+package libsmb2
+
+import (
+	"errors"
+	"io/fs"
+	"iter"
+	"os"
+)
+
+// errStopIter ends a walk when the range loop over it breaks.
+var errStopIter = errors.New("iteration stopped")
+
+// WalkEntry is a visited entry of WalkSeq, with its full path.
+type WalkEntry struct {
+	Path  string
+	Entry fs.DirEntry
+}
+
+// Entries ranges over the entries of dir in lexical order. An error
+// listing dir is yielded once, with a nil entry.
+func (s *Smb) Entries(dir string) iter.Seq2[fs.DirEntry, error] {
+	return func(yield func(fs.DirEntry, error) bool) {
+		s.mutex.Lock()
+		infos, err := s.listDir(dir)
+		s.mutex.Unlock()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, info := range infos {
+			if !yield(fs.FileInfoToDirEntry(info), nil) {
+				return
+			}
+		}
+	}
+}
+
+// WalkSeq ranges over the tree rooted at root in the order of Walk.
+// Breaking out of the loop ends the walk. Errors are yielded with the path
+// they concern and the walk goes on; there is no way to skip a directory
+// from the loop, use Walk for that.
+func (s *Smb) WalkSeq(root string) iter.Seq2[WalkEntry, error] {
+	return func(yield func(WalkEntry, error) bool) {
+		s.Walk(root, func(path string, info os.FileInfo, err error) error {
+			entry := WalkEntry{Path: path}
+			if info != nil {
+				entry.Entry = fs.FileInfoToDirEntry(info)
+			}
+			if !yield(entry, err) {
+				return errStopIter
+			}
+			return nil
+		})
+	}
+}
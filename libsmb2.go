@@ -1,22 +1,67 @@
 package libsmb2
 
 import (
-	"errors"
-	"fmt"
 	"io"
+	"log"
 	"os"
 	path2 "path"
+	"sort"
 	"sync"
 	"time"
 	"unsafe"
 )
+//#include <stdlib.h>
 //#include "libsmb2go.h"
 import "C"
 
 type Smb struct {
 	session *C.struct_smb2_context
+	host string
+	share string
 	connected bool
 	mutex  sync.Mutex
+	slowThreshold time.Duration
+	slowLogger *log.Logger
+	zeroizeCredentials bool
+	faults faultState
+	names NameMapping
+	reservedNames ReservedNamePolicy
+	recycleDir string
+	versioning *Versioning
+	readdir ReaddirOptions
+	middleware []Middleware
+	rewrite PathRewriter
+	tags map[string]string
+	files map[*smbFile]struct{}
+	draining bool
+	inflight int
+	idle chan struct{}
+	healthProbe string
+	reaperStop chan struct{}
+	maxHandles int
+	waitHandles bool
+	handleFreed *sync.Cond
+	affinity *Affinity
+	remoteAddr string
+	encryption KeyProvider
+	compression Compressor
+	hidden HiddenFunc
+	rangeMutex sync.Mutex
+	ranges map[string]*sharedHandle
+	prewarmCheck time.Duration
+	resolver *ResolverOptions
+	attempts []Attempt
+	seal bool
+	kerberos bool
+	idleTimeout time.Duration
+	strict bool
+	security *SecurityReport
+	timeout time.Duration
+	priority PriorityFunc
+	scheduler *scheduler
+	backoff *BackoffOptions
+	triedAddr string
+	writers map[*layeredWriter]struct{}
 }
 
 type cSmbStat struct {
@@ -30,6 +75,7 @@ type smbStat struct {
 	modTime time.Time
 	mode os.FileMode
 	size int64
+	sys *FileStat
 }
 
 type smbFile struct {
@@ -38,6 +84,18 @@ type smbFile struct {
 	dir		*C.struct_smb2dir
 	path	string
 	pos		int64
+	// listing holds the rest of a snapshot taken by Readdir.
+	listing	[]os.FileInfo
+	snapshot	bool
+	flags	int
+	opened	time.Time
+	bytesRead	int64
+	bytesWritten	int64
+	used	time.Time
+	reaped	bool
+	dirty	bool
+	deadline	time.Time
+	final	bool
 	*smbStat
 	mutex  sync.Mutex
 }
@@ -50,21 +108,62 @@ func NewSmb() *Smb {
 }
 
 func (s *Smb) Connect(host string, share string, user string, password string) error {
+	s.mutex.Lock()
+	backoff := s.backoff
+	s.mutex.Unlock()
+	if backoff != nil {
+		return s.connectBackoff(host, share, user, password, backoff)
+	}
+	return s.invoke("connect", host+"/"+share, 0, func() error {
+		return s.connect(host, share, user, password)
+	})
+}
+
+func (s *Smb) connect(host string, share string, user string, password string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	defer s.logSlow("connect", host+"/"+share, 0, time.Now())
+	s.host, s.share = host, share
+	if s.session == nil {
+		return s.wrapError("connect", "", ErrClosed)
+	}
+	if err := s.injectFault("connect", host+"/"+share, 0); err != nil {
+		return s.wrapError("connect", "", err)
+	}
+	if err := s.strictCheck(user); err != nil {
+		return err
+	}
+	s.triedAddr = ""
+	server, err := s.serverAddress(host)
+	if err != nil {
+		return s.wrapError("connect", "", err)
+	}
+	s.triedAddr = server
 	C.smb2_set_user(s.session, C.CString(user))
-	C.smb2_set_password(s.session, C.CString(password))
+	cPassword := C.CString(password)
+	C.smb2_set_password(s.session, cPassword)
+	wipeCString(cPassword)
 
-	if code := C.smb2_connect_share(s.session, C.CString(host), C.CString(share), C.CString(user)); code == 0 {
+	var cerr cError
+	if code := C.smb2_connect_wrapper(s.session, C.CString(server), C.CString(share), C.CString(user), cerr.ptr(), cerr.len()); code == 0 {
 		s.connected = true
+		s.remoteAddr = server
+		s.recordSecurity()
+		if s.zeroizeCredentials {
+			C.smb2_set_password(s.session, nil)
+		}
 		return nil
 	} else {
+		msg := redact(cerr.String(), password)
+		err := s.strictFailure(s.newError("connect", "", int(code), msg), msg)
 		s.disconnect()
-		return errors.New(fmt.Sprintf("unable to connect to %s, code %d, %s", host, int(code), C.GoString(C.smb2_get_error(s.session))))
+		return err
 	}
 }
 
 func (s *Smb) disconnect() {
+	s.stopReaper()
+	defer s.handleReleased()
 	if s.session != nil {
 		if s.connected {
 			C.smb2_disconnect_share(s.session)
@@ -81,57 +180,199 @@ func (s* Smb) Disconnect() {
 }
 
 
+// OpenFile opens path for raw access to its bytes. With encryption set
+// it fails, since the file would read and write sealed content; use
+// OpenReader and Create then.
 func (s* Smb) OpenFile(path string, mode int) (*smbFile, error) {
+	if s.encrypted() {
+		return nil, s.wrapError("open", path, errEncrypted{})
+	}
+	return s.open(path, mode)
+}
+
+// open is OpenFile without the encryption check, for the package's own
+// use on content it layers itself or keeps in the clear.
+func (s *Smb) open(path string, mode int) (file *smbFile, err error) {
+	err = s.invoke("open", path, 0, func() (err error) {
+		file, err = s.openFile(path, mode)
+		return
+	})
+	return
+}
+
+func (s *Smb) openFile(path string, mode int) (*smbFile, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	if s.session == nil {
-		return nil, errors.New("opening file on closed session")
+		return nil, s.wrapError("open", path, ErrClosed)
+	}
+	defer s.logSlow("open", path, 0, time.Now())
+	if err := s.injectFault("open", path, 0); err != nil {
+		return nil, s.wrapError("open", path, err)
+	}
+	path, err := s.createName("open", path, mode)
+	if err != nil {
+		return nil, err
 	}
 	file := &smbFile{
 		smb: s,
 		path: path,
+		flags: mode,
+		opened: time.Now(),
+		used: time.Now(),
+	}
+	cPath, err := s.cPath("open", path)
+	if err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	if err := s.reserveHandle("open", path); err != nil {
+		return nil, err
 	}
-	if file.fd = C.smb2_open(s.session, C.CString(path), C.int(mode)); file.fd == nil {
-		if file.dir = C.smb2_opendir(s.session, C.CString(path)); file.dir == nil {
-			return nil, errors.New(fmt.Sprintf("file open failed "+C.GoString(C.smb2_get_error(s.session))))
+	var cerr, dirErr cError
+	if file.fd = C.smb2_open_wrapper(s.session, cPath, C.int(mode), cerr.ptr(), cerr.len()); file.fd == nil {
+		if file.dir = C.smb2_opendir_wrapper(s.session, cPath, dirErr.ptr(), dirErr.len()); file.dir == nil {
+			if errorCause(0, cerr.String()) == StatusFileIsADirectory {
+				return nil, s.newError("readdir", path, 0, dirErr.String())
+			}
+			return nil, withAccess(s.newError("open", path, 0, cerr.String()), accessForFlags(mode))
 		} else {
 			file.smbStat=&smbStat{}
 			file.smbStat.isDir = true
 			file.smbStat.name = path2.Base(path)
-			file.smbStat.modTime = time.Now()
+			file.smbStat.modTime = time.Now().UTC()
 		}
 	} else {
 		st := cSmbStat{name: path2.Base(path)}
 		C.smb2_fstat(s.session, file.fd, &st.smbStat)
 		file.smbStat = st.toGoStat()
 	}
+	s.track(file)
 	return file, nil
 }
 
+func (s *Smb) Stat(path string) (info os.FileInfo, err error) {
+	err = s.invoke("stat", path, 0, func() (err error) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		info, err = s.stat(path)
+		return
+	})
+	return
+}
+
+// stat must be called with s.mutex held.
+func (s *Smb) stat(path string) (os.FileInfo, error) {
+	if s.session == nil {
+		return nil, s.wrapError("stat", path, ErrClosed)
+	}
+	defer s.logSlow("stat", path, 0, time.Now())
+	if err := s.injectFault("stat", path, 0); err != nil {
+		return nil, s.wrapError("stat", path, err)
+	}
+	cPath, err := s.cPath("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	st := cSmbStat{name: path2.Base(path)}
+	var cerr cError
+	if code := C.smb2_stat_wrapper(s.session, cPath, &st.smbStat, cerr.ptr(), cerr.len()); code < 0 {
+		return nil, s.newError("stat", path, int(code), cerr.String())
+	}
+	return st.toGoStat(), nil
+}
+
 func (f *smbFile) Read(p []byte) (n int, err error) {
+	err = f.smb.invokeN("read", f.path, len(p), func() (int, error) {
+		n, err = f.read(p)
+		return n, err
+	})
+	return
+}
+
+func (f *smbFile) read(p []byte) (n int, err error) {
 	f.smb.mutex.Lock()
 	defer f.smb.mutex.Unlock()
+	if err = f.revive(); err != nil {
+		return 0, err
+	}
 	if f.fd == nil || f.smb.session == nil {
 		return 0, io.EOF
 	}
-	n=int(C.smb2_read_wrapper(f.smb.session, f.fd, unsafe.Pointer(&p[0]), C.ulong(len(p)), C.longlong(f.pos)))
-	if n <= 0 {
+	defer func(start time.Time) { f.smb.logSlow("read", f.path, int64(n), start) }(time.Now())
+	if err = f.smb.injectFault("read", f.path, len(p)); err != nil {
+		return 0, f.smb.wrapError("read", f.path, err)
+	}
+	var restore func()
+	if restore, err = f.applyDeadline("read"); err != nil {
+		return 0, err
+	}
+	defer restore()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var cerr cError
+	n=int(C.smb2_read_wrapper(f.smb.session, f.fd, unsafe.Pointer(&p[0]), C.ulong(len(p)), C.longlong(f.pos), cerr.ptr(), cerr.len()))
+	if n < 0 {
+		err = f.smb.newError("read", f.path, n, cerr.String())
+		n = 0
+	} else if n == 0 {
 		err=io.EOF
 	} else {
 		f.pos+=int64(n)
+		f.bytesRead+=int64(n)
+		f.used = time.Now()
 	}
 	return
 }
 
 func (f *smbFile) Write(p []byte) (n int, err error) {
+	f.smb.mutex.Lock()
+	final := f.final
+	f.smb.mutex.Unlock()
+	if final {
+		// Shutdown is closing the layered writer on top of f.
+		return f.write(p)
+	}
+	err = f.smb.invokeN("write", f.path, len(p), func() (int, error) {
+		n, err = f.write(p)
+		return n, err
+	})
+	return
+}
+
+func (f *smbFile) write(p []byte) (n int, err error) {
 	f.smb.mutex.Lock()
 	defer f.smb.mutex.Unlock()
+	if err = f.revive(); err != nil {
+		return 0, err
+	}
 	if f.fd == nil || f.smb.session == nil {
 		return 0, io.EOF
 	}
-	n=int(C.smb2_write_wrapper(f.smb.session, f.fd, unsafe.Pointer(&p[0]), C.ulong(len(p))));
+	defer func(start time.Time) { f.smb.logSlow("write", f.path, int64(n), start) }(time.Now())
+	if err = f.smb.injectFault("write", f.path, len(p)); err != nil {
+		return 0, f.smb.wrapError("write", f.path, err)
+	}
+	var restore func()
+	if restore, err = f.applyDeadline("write"); err != nil {
+		return 0, err
+	}
+	defer restore()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var cerr cError
+	n=int(C.smb2_write_wrapper(f.smb.session, f.fd, unsafe.Pointer(&p[0]), C.ulong(len(p)), C.longlong(f.pos), cerr.ptr(), cerr.len()));
 	if n <= 0 {
-		err = errors.New("write error "+C.GoString(C.smb2_get_error(f.smb.session)))
+		err = f.smb.newError("write", f.path, n, cerr.String())
+		n = 0
+	} else {
+		f.pos+=int64(n)
+		f.bytesWritten+=int64(n)
+		f.dirty = true
+		f.used = time.Now()
 	}
 	return
 }
@@ -140,37 +381,110 @@ func (f *smbFile) Stat() (os.FileInfo, error) {
 	return f, nil
 }
 
-func (f *smbFile) Seek(offset int64, whence int) (res int64, err error){
+func (f *smbFile) Seek(offset int64, whence int) (res int64, err error) {
+	err = f.smb.invoke("seek", f.path, 0, func() (err error) {
+		res, err = f.seek(offset, whence)
+		return
+	})
+	return
+}
+
+func (f *smbFile) seek(offset int64, whence int) (res int64, err error){
 	f.smb.mutex.Lock()
 	defer f.smb.mutex.Unlock()
+	if err = f.revive(); err != nil {
+		return 0, err
+	}
 	if f.fd == nil || f.smb.session == nil {
 		return 0, io.EOF
 	}
+	if err = f.smb.injectFault("seek", f.path, 0); err != nil {
+		return 0, f.smb.wrapError("seek", f.path, err)
+	}
 	realOffset := offset
 	if whence == io.SeekEnd {
 		realOffset = f.Size() + offset
 		whence = io.SeekStart
+	} else if whence == io.SeekCurrent {
+		// Reads and writes are positioned by f.pos, not the handle offset.
+		realOffset = f.pos + offset
+		whence = io.SeekStart
 	}
-	res = int64(C.smb2_lseek_wrapper(f.smb.session, f.fd, C.longlong(realOffset), C.int(whence)))
+	var cerr cError
+	res = int64(C.smb2_lseek_wrapper(f.smb.session, f.fd, C.longlong(realOffset), C.int(whence), cerr.ptr(), cerr.len()))
 	if res < 0 {
-		err = errors.New("seek error: "+C.GoString(C.smb2_get_error(f.smb.session)))
+		err = f.smb.newError("seek", f.path, int(res), cerr.String())
 	} else {
 		f.pos = res
 	}
 	return
 }
 
+// Readdir reads the next count entries of the directory, or all that are
+// left when count <= 0. It is safe for concurrent use, as when an HTTP
+// file server hands the same directory to several requests: calls are
+// serialized and share the directory's one cursor, so each entry goes to
+// exactly one caller and a batch is never interleaved with another's.
+// Callers that each need the full listing should open the directory
+// themselves or use ReadDir. A call racing with Close fails with
+// ErrClosed.
 func (f *smbFile) Readdir(count int) (infos []os.FileInfo, err error) {
+	err = f.smb.invoke("readdir", f.path, 0, func() (err error) {
+		infos, err = f.readdir(count)
+		return
+	})
+	return
+}
+
+func (f *smbFile) readdir(count int) (infos []os.FileInfo, err error) {
 	f.smb.mutex.Lock()
 	defer f.smb.mutex.Unlock()
-	list := C.smb2_opendir(f.smb.session, C.CString(f.path))
-	defer C.smb2_closedir(f.smb.session, list)
-	infos=make([]os.FileInfo, 0)
-	ent := C.smb2_readdir(f.smb.session, list)
-	for i:=0; ent!=nil && ( count <= 0 || i<count); i++ {
-		st := cSmbStat{name: C.GoString(ent.name), smbStat: ent.st}
-		infos = append(infos, st.toGoStat())
-		ent = C.smb2_readdir(f.smb.session, list)
+	defer func(start time.Time) { f.smb.logSlow("readdir", f.path, int64(len(infos)), start) }(time.Now())
+	if err = f.smb.injectFault("readdir", f.path, 0); err != nil {
+		return nil, f.smb.wrapError("readdir", f.path, err)
+	}
+	if f.smb.session == nil {
+		return nil, f.smb.wrapError("readdir", f.path, ErrClosed)
+	}
+	var restore func()
+	if restore, err = f.applyDeadline("readdir"); err != nil {
+		return nil, err
+	}
+	defer restore()
+	if f.dir == nil && !f.snapshot {
+		if f.IsDir() {
+			return nil, f.smb.wrapError("readdir", f.path, ErrClosed)
+		}
+		return nil, f.smb.wrapError("readdir", f.path, StatusNotADirectory)
+	}
+	if f.smb.readdir.Snapshot && !f.snapshot {
+		f.listing = f.smb.visible(f.readAll())
+		if f.smb.readdir.Sort {
+			sort.Slice(f.listing, func(i, j int) bool { return f.listing[i].Name() < f.listing[j].Name() })
+		}
+		f.snapshot = true
+	}
+	if f.snapshot {
+		n := len(f.listing)
+		if count > 0 && count < n {
+			n = count
+		}
+		infos = append(make([]os.FileInfo, 0, n), f.listing[:n]...)
+		f.listing = f.listing[n:]
+	} else {
+		infos=make([]os.FileInfo, 0)
+		for i:=0; count <= 0 || i<count; i++ {
+			ent := C.smb2_readdir(f.smb.session, f.dir)
+			if ent == nil {
+				break
+			}
+			st := cSmbStat{name: f.smb.clientName(C.GoString(ent.name)), smbStat: ent.st}
+			if f.smb.hidden != nil && f.smb.hidden(st.name) {
+				i--
+				continue
+			}
+			infos = append(infos, st.toGoStat())
+		}
 	}
 	if len(infos) < 1 {
 		err = io.EOF
@@ -178,19 +492,49 @@ func (f *smbFile) Readdir(count int) (infos []os.FileInfo, err error) {
 	return
 }
 
+// readAll drains the directory handle and closes it, so a snapshot holds
+// the handle only for the time of one pass. Must be called with the
+// session mutex held.
+func (f *smbFile) readAll() []os.FileInfo {
+	var infos []os.FileInfo
+	for ent := C.smb2_readdir(f.smb.session, f.dir); ent != nil; ent = C.smb2_readdir(f.smb.session, f.dir) {
+		st := cSmbStat{name: f.smb.clientName(C.GoString(ent.name)), smbStat: ent.st}
+		infos = append(infos, st.toGoStat())
+	}
+	C.smb2_closedir(f.smb.session, f.dir)
+	f.dir = nil
+	return infos
+}
+
 func (f *smbFile) Close() error {
+	return f.smb.invoke("close", f.path, 0, f.close)
+}
+
+func (f *smbFile) close() error {
 	f.smb.mutex.Lock()
 	defer f.smb.mutex.Unlock()
-	if f.fd == nil || f.smb.session == nil {
+	if f.smb.session == nil {
 		return nil
 	}
+	f.release()
+	return nil
+}
+
+// release closes the handles of f and forgets it. Must be called with the
+// session mutex held.
+func (f *smbFile) release() {
 	if f.fd != nil {
 		C.smb2_close(f.smb.session, f.fd)
 	} else if f.dir != nil {
 		C.smb2_closedir(f.smb.session, f.dir)
 	}
 	f.fd = nil
-	return nil
+	f.dir = nil
+	f.listing = nil
+	f.snapshot = false
+	f.reaped = false
+	delete(f.smb.files, f)
+	f.smb.handleReleased()
 }
 
 func (f *cSmbStat) Name() string {
@@ -198,11 +542,11 @@ func (f *cSmbStat) Name() string {
 }
 
 func (f *cSmbStat) IsDir() bool {
-	return os.FileMode(uint32(f.smbStat.smb2_type)).IsDir()
+	return f.smbStat.smb2_type == C.SMB2_TYPE_DIRECTORY
 }
 
 func (f *cSmbStat) ModTime() time.Time {
-	return time.Unix(int64(f.smbStat.smb2_mtime),0)
+	return smbTime(f.smbStat.smb2_mtime, f.smbStat.smb2_mtime_nsec)
 }
 
 func (f *cSmbStat) Size() int64 {
@@ -210,7 +554,13 @@ func (f *cSmbStat) Size() int64 {
 }
 
 func (f *cSmbStat) Mode() os.FileMode {
-	return 666
+	switch f.smbStat.smb2_type {
+	case C.SMB2_TYPE_DIRECTORY:
+		return os.ModeDir | 0777
+	case C.SMB2_TYPE_LINK:
+		return os.ModeSymlink | 0777
+	}
+	return 0666
 }
 
 func (f *smbStat) Name() string {
@@ -234,7 +584,10 @@ func (f *smbStat) Mode() os.FileMode {
 }
 
 func (f *smbStat) Sys() interface{} {
-	return nil
+	if f.sys == nil {
+		return nil
+	}
+	return f.sys
 }
 
 func (f *cSmbStat) toGoStat() *smbStat {
@@ -244,11 +597,12 @@ func (f *cSmbStat) toGoStat() *smbStat {
 		modTime:  f.ModTime(),
 		mode:     f.Mode(),
 		size:	  f.Size(),
+		sys:      newFileStat(&f.smbStat),
 	}
 }
 
 func (f *cSmbStat) Sys() interface{} {
-	return nil
+	return newFileStat(&f.smbStat)
 }
 
 
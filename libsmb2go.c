@@ -1,13 +1,200 @@
+#include <stdio.h>
 #include "libsmb2go.h"
 
-int smb2_read_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset) {
-	return smb2_pread(smb2, fh, (uint8_t*) buf, count, offset);
+/* The optional functions are referenced weakly where the object format
+ * allows it, so a libsmb2 without them still links and the missing ones
+ * resolve to NULL, to be reported at run time. Elsewhere only the
+ * LIBSMB2GO_HAVE_* macros decide. */
+#if defined(__ELF__)
+#pragma weak smb2_set_seal
+#pragma weak smb2_readlink
+#pragma weak smb2_statvfs
+#define LIBSMB2GO_PRESENT(fn) ((fn) != NULL)
+#else
+#define LIBSMB2GO_PRESENT(fn) 1
+#endif
+
+static void capture_error(struct smb2_context *smb2, char *err, size_t errlen) {
+	if (err != NULL && errlen > 0) {
+		snprintf(err, errlen, "%s", smb2_get_error(smb2));
+	}
+}
+
+int smb2_connect_wrapper(struct smb2_context *smb2, const char *server, const char *share, const char *user, char *err, size_t errlen) {
+	int rc = smb2_connect_share(smb2, server, share, user);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+struct smb2fh *smb2_open_wrapper(struct smb2_context *smb2, const char *path, int flags, char *err, size_t errlen) {
+	struct smb2fh *fh = smb2_open(smb2, path, flags);
+	if (fh == NULL) {
+		capture_error(smb2, err, errlen);
+	}
+	return fh;
+}
+
+struct smb2dir *smb2_opendir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen) {
+	struct smb2dir *dir = smb2_opendir(smb2, path);
+	if (dir == NULL) {
+		capture_error(smb2, err, errlen);
+	}
+	return dir;
+}
+
+int smb2_read_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset, char *err, size_t errlen) {
+	int rc = smb2_pread(smb2, fh, (uint8_t*) buf, count, offset);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_write_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset, char *err, size_t errlen) {
+	int rc = smb2_pwrite(smb2, fh, (uint8_t*) buf, count, offset);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int64_t smb2_lseek_wrapper(struct smb2_context *smb2, struct smb2fh *fh, long long offset, int whence, char *err, size_t errlen) {
+	int64_t rc = smb2_lseek(smb2, fh, offset, whence, NULL);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_stat_wrapper(struct smb2_context *smb2, const char *path, struct smb2_stat_64 *st, char *err, size_t errlen) {
+	int rc = smb2_stat(smb2, path, st);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_mkdir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen) {
+	int rc = smb2_mkdir(smb2, path);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_rmdir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen) {
+	int rc = smb2_rmdir(smb2, path);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
 }
 
-int smb2_write_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count) {
-	return smb2_write(smb2, fh, (uint8_t*) buf, count);
+int smb2_unlink_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen) {
+	int rc = smb2_unlink(smb2, path);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
 }
 
-int64_t smb2_lseek_wrapper(struct smb2_context *smb2, struct smb2fh *fh, long long offset, int whence) {
-	return smb2_lseek(smb2, fh, offset, whence, NULL);
-}
\ No newline at end of file
+int smb2_rename_wrapper(struct smb2_context *smb2, const char *oldpath, const char *newpath, char *err, size_t errlen) {
+	int rc = smb2_rename(smb2, oldpath, newpath);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_echo_wrapper(struct smb2_context *smb2, char *err, size_t errlen) {
+	int rc = smb2_echo(smb2);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+int smb2_fsync_wrapper(struct smb2_context *smb2, struct smb2fh *fh, char *err, size_t errlen) {
+	int rc = smb2_fsync(smb2, fh);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+}
+
+const char *libsmb2go_libsmb2_version(void) {
+	return LIBSMB2GO_LIBSMB2_VERSION;
+}
+
+
+int libsmb2go_capabilities(void) {
+	int caps = 0;
+	if (LIBSMB2GO_HAVE_KRB5) caps |= LIBSMB2GO_CAP_KRB5;
+#if LIBSMB2GO_HAVE_SEAL
+	if (LIBSMB2GO_PRESENT(smb2_set_seal)) caps |= LIBSMB2GO_CAP_SEAL;
+#endif
+#if LIBSMB2GO_HAVE_READLINK
+	if (LIBSMB2GO_PRESENT(smb2_readlink)) caps |= LIBSMB2GO_CAP_READLINK;
+#endif
+#if LIBSMB2GO_HAVE_STATVFS
+	if (LIBSMB2GO_PRESENT(smb2_statvfs)) caps |= LIBSMB2GO_CAP_STATVFS;
+#endif
+	return caps;
+}
+
+int libsmb2go_set_seal(struct smb2_context *smb2, int val) {
+#if LIBSMB2GO_HAVE_SEAL
+	if (!LIBSMB2GO_PRESENT(smb2_set_seal)) {
+		return -ENOTSUP;
+	}
+	smb2_set_seal(smb2, val);
+	return 0;
+#else
+	return -ENOTSUP;
+#endif
+}
+
+int libsmb2go_set_authentication(struct smb2_context *smb2, int krb5) {
+#if LIBSMB2GO_HAVE_KRB5
+	smb2_set_authentication(smb2, krb5 ? SMB2_SEC_KRB5 : SMB2_SEC_NTLMSSP);
+	return 0;
+#else
+	if (krb5) {
+		return -ENOTSUP;
+	}
+	smb2_set_authentication(smb2, SMB2_SEC_NTLMSSP);
+	return 0;
+#endif
+}
+
+int libsmb2go_statvfs(struct smb2_context *smb2, const char *path, struct smb2_statvfs *st, char *err, size_t errlen) {
+#if LIBSMB2GO_HAVE_STATVFS
+	if (!LIBSMB2GO_PRESENT(smb2_statvfs)) {
+		return -ENOTSUP;
+	}
+	int rc = smb2_statvfs(smb2, path, st);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+#else
+	return -ENOTSUP;
+#endif
+}
+
+int libsmb2go_readlink(struct smb2_context *smb2, const char *path, char *buf, unsigned int bufsiz, char *err, size_t errlen) {
+#if LIBSMB2GO_HAVE_READLINK
+	if (!LIBSMB2GO_PRESENT(smb2_readlink)) {
+		return -ENOTSUP;
+	}
+	int rc = smb2_readlink(smb2, path, buf, bufsiz);
+	if (rc < 0) {
+		capture_error(smb2, err, errlen);
+	}
+	return rc;
+#else
+	return -ENOTSUP;
+#endif
+}
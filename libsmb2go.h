@@ -1,10 +1,75 @@
+#include <errno.h>
+#include <stddef.h>
 #include <stdint.h>
 #include <unistd.h>
 #include <smb2.h>
 #include <libsmb2.h>
 
-int smb2_read_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset);
+/* The wrappers copy the libsmb2 error text into err as soon as a call
+ * fails, so every operation reports its own error rather than whatever
+ * the shared context holds by the time the caller looks. */
 
-int smb2_write_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count);
+int smb2_connect_wrapper(struct smb2_context *smb2, const char *server, const char *share, const char *user, char *err, size_t errlen);
 
-int64_t smb2_lseek_wrapper(struct smb2_context *smb2, struct smb2fh *fh, long long offset, int whence);
\ No newline at end of file
+struct smb2fh *smb2_open_wrapper(struct smb2_context *smb2, const char *path, int flags, char *err, size_t errlen);
+
+struct smb2dir *smb2_opendir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen);
+
+int smb2_read_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset, char *err, size_t errlen);
+
+int smb2_write_wrapper(struct smb2_context *smb2, struct smb2fh *fh, void *buf, unsigned long count, long long offset, char *err, size_t errlen);
+
+int64_t smb2_lseek_wrapper(struct smb2_context *smb2, struct smb2fh *fh, long long offset, int whence, char *err, size_t errlen);
+
+int smb2_stat_wrapper(struct smb2_context *smb2, const char *path, struct smb2_stat_64 *st, char *err, size_t errlen);
+
+int smb2_mkdir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen);
+
+int smb2_rmdir_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen);
+
+int smb2_unlink_wrapper(struct smb2_context *smb2, const char *path, char *err, size_t errlen);
+
+int smb2_rename_wrapper(struct smb2_context *smb2, const char *oldpath, const char *newpath, char *err, size_t errlen);
+
+int smb2_echo_wrapper(struct smb2_context *smb2, char *err, size_t errlen);
+
+int smb2_fsync_wrapper(struct smb2_context *smb2, struct smb2fh *fh, char *err, size_t errlen);
+
+#ifndef LIBSMB2GO_LIBSMB2_VERSION
+#define LIBSMB2GO_LIBSMB2_VERSION "unknown"
+#endif
+
+const char *libsmb2go_libsmb2_version(void);
+
+
+/* Optional features. Define to 0 in CGO_CFLAGS to compile them out. On
+ * ELF platforms the seal, readlink and statvfs functions are also looked
+ * for when the program runs, see libsmb2go.c; elsewhere a libsmb2 lacking
+ * one of them needs its macro set to 0 to link. */
+#ifndef LIBSMB2GO_HAVE_KRB5
+#define LIBSMB2GO_HAVE_KRB5 0
+#endif
+#ifndef LIBSMB2GO_HAVE_SEAL
+#define LIBSMB2GO_HAVE_SEAL 1
+#endif
+#ifndef LIBSMB2GO_HAVE_READLINK
+#define LIBSMB2GO_HAVE_READLINK 1
+#endif
+#ifndef LIBSMB2GO_HAVE_STATVFS
+#define LIBSMB2GO_HAVE_STATVFS 1
+#endif
+
+#define LIBSMB2GO_CAP_KRB5     0x01
+#define LIBSMB2GO_CAP_SEAL     0x02
+#define LIBSMB2GO_CAP_READLINK 0x04
+#define LIBSMB2GO_CAP_STATVFS  0x08
+
+int libsmb2go_capabilities(void);
+
+int libsmb2go_set_seal(struct smb2_context *smb2, int val);
+
+int libsmb2go_set_authentication(struct smb2_context *smb2, int krb5);
+
+int libsmb2go_statvfs(struct smb2_context *smb2, const char *path, struct smb2_statvfs *st, char *err, size_t errlen);
+
+int libsmb2go_readlink(struct smb2_context *smb2, const char *path, char *buf, unsigned int bufsiz, char *err, size_t errlen);
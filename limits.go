@@ -0,0 +1,90 @@
+package libsmb2
+
+import (
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+//#include <stdlib.h>
+//#include "libsmb2go.h"
+import "C"
+
+const (
+	// maxPathLength is the longest path SMB2 can carry, in UTF-16 code
+	// units: the 16 bit name length field of CREATE counts bytes.
+	maxPathLength = 32767
+	// maxComponentLength is the longest single name NTFS and most NAS
+	// file systems accept, in UTF-16 code units.
+	maxComponentLength = 255
+)
+
+// Limits describes the size limits that apply to a session.
+type Limits struct {
+	// MaxPathLength is the longest accepted path in UTF-16 code units.
+	// There is no MAX_PATH (260) limit on the client side.
+	MaxPathLength int
+	// MaxComponentLength is the longest accepted name in UTF-16 code units.
+	MaxComponentLength int
+	// MaxReadSize and MaxWriteSize are the largest single read and write
+	// the server negotiated, zero before Connect.
+	MaxReadSize  uint32
+	MaxWriteSize uint32
+}
+
+// Limits reports the limits that apply to the session.
+func (s *Smb) Limits() Limits {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	limits := Limits{
+		MaxPathLength:      maxPathLength,
+		MaxComponentLength: maxComponentLength,
+	}
+	if s.session != nil && s.connected {
+		limits.MaxReadSize = uint32(C.smb2_get_max_read_size(s.session))
+		limits.MaxWriteSize = uint32(C.smb2_get_max_write_size(s.session))
+	}
+	return limits
+}
+
+// utf16Len returns the length of name in UTF-16 code units.
+func utf16Len(name string) int {
+	n := 0
+	for _, c := range name {
+		if utf16.IsSurrogate(c) || c < 0x10000 {
+			n++
+		} else {
+			n += 2
+		}
+	}
+	return n
+}
+
+// checkPath rejects server paths beyond the SMB2 limits before they are
+// sent, with a cause of syscall.ENAMETOOLONG.
+func checkPath(path string) error {
+	if utf16Len(path) > maxPathLength {
+		return syscall.ENAMETOOLONG
+	}
+	for _, part := range strings.Split(path, "/") {
+		if utf16Len(part) > maxComponentLength {
+			return syscall.ENAMETOOLONG
+		}
+	}
+	return nil
+}
+
+// cPath rewrites and maps path for the server, checks it against the
+// limits and returns it as a C string to be released with C.free. Must be
+// called with s.mutex held.
+func (s *Smb) cPath(op string, path string) (*C.char, error) {
+	rewritten, err := s.rewritePath(op, path)
+	if err != nil {
+		return nil, s.wrapError(op, path, err)
+	}
+	server := s.serverPath(rewritten)
+	if err := checkPath(server); err != nil {
+		return nil, s.wrapError(op, path, err)
+	}
+	return C.CString(server), nil
+}
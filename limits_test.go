@@ -0,0 +1,63 @@
+package libsmb2
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"", 0},
+		{"report.txt", 10},
+		{"résumé", 6},
+		{"日本語", 3},
+		{"😀", 2},
+		{"a😀b", 4},
+		{strings.Repeat("😀", 128), 256},
+	}
+	for _, tt := range tests {
+		if got := utf16Len(tt.name); got != tt.want {
+			t.Errorf("utf16Len(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	// deep joins n components of 50 characters.
+	deep := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = strings.Repeat("d", 50)
+		}
+		return strings.Join(parts, "/")
+	}
+	tests := []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{"short", "dir/file.txt", true},
+		{"beyond MAX_PATH", deep(6), true},
+		{"path at limit", strings.Repeat("d/", maxPathLength/2) + "f", true},
+		{"path over limit", strings.Repeat("d/", maxPathLength/2) + "ff", false},
+		{"component at limit", "dir/" + strings.Repeat("n", maxComponentLength), true},
+		{"component over limit", "dir/" + strings.Repeat("n", maxComponentLength+1), false},
+		{"surrogate pairs at limit", strings.Repeat("😀", 127) + "n", true},
+		{"surrogate pairs over limit", strings.Repeat("😀", 128), false},
+		{"multibyte under limit", strings.Repeat("日", maxComponentLength), true},
+	}
+	for _, tt := range tests {
+		err := checkPath(tt.path)
+		if tt.ok && err != nil {
+			t.Errorf("%s: checkPath() = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, syscall.ENAMETOOLONG) {
+			t.Errorf("%s: checkPath() = %v, want ENAMETOOLONG", tt.name, err)
+		}
+	}
+}
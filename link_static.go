@@ -0,0 +1,14 @@
+//go:build libsmb2_static && !libsmb2_vendored
+// +build libsmb2_static,!libsmb2_vendored
+
+package libsmb2
+
+// #cgo LDFLAGS: -Wl,-Bstatic -lsmb2 -Wl,-Bdynamic
+// #include "libsmb2go.h"
+import "C"
+
+const linkage = "static"
+
+func linkedVersion() string {
+	return C.GoString(C.libsmb2go_libsmb2_version())
+}
@@ -0,0 +1,17 @@
+//go:build libsmb2_vendored
+// +build libsmb2_vendored
+
+package libsmb2
+
+// #cgo CFLAGS: -I${SRCDIR}/third_party/libsmb2/include/smb2
+// #cgo LDFLAGS: ${SRCDIR}/third_party/libsmb2/lib/libsmb2.a
+import "C"
+
+const linkage = "vendored"
+
+// vendoredVersion must match LIBSMB2_VERSION in third_party/build-libsmb2.sh.
+const vendoredVersion = "v6.1.0"
+
+func linkedVersion() string {
+	return vendoredVersion
+}
@@ -0,0 +1,178 @@
+package libsmb2
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotateStamp names rotated logs; it sorts in rotation order.
+const rotateStamp = "20060102T150405.000000000Z"
+
+// LogOptions sets when a LogWriter rotates. Zero values disable the
+// corresponding trigger.
+type LogOptions struct {
+	// MaxSize rotates before a record would take the file past it.
+	MaxSize int64
+	// MaxAge rotates the first record after the file is that old.
+	MaxAge time.Duration
+	// Compress gzips rotated files to name.<stamp>.gz.
+	Compress bool
+}
+
+// LogWriter appends records to a log file on a share, rotating it to
+// path.<UTC stamp> as configured. Every Write is one record and is written
+// whole; concurrent Writes do not interleave. Appending cannot extend a
+// sealed file, so with encryption set on the session it fails with
+// ErrNotSupported.
+type LogWriter struct {
+	smb    *Smb
+	path   string
+	opts   LogOptions
+	mutex  sync.Mutex
+	file   *smbFile
+	size   int64
+	opened time.Time
+	closed bool
+}
+
+// NewLogWriter opens path for appending, creating it if needed.
+func NewLogWriter(s *Smb, path string, opts LogOptions) (*LogWriter, error) {
+	l := &LogWriter{smb: s, path: path, opts: opts}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LogWriter) open() error {
+	f, err := l.smb.OpenFile(l.path, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size, l.opened = f, size, time.Now()
+	return nil
+}
+
+func (l *LogWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return 0, l.smb.wrapError("write", l.path, ErrClosed)
+	}
+	if l.smb.encrypted() {
+		// Appending cannot extend a sealed file.
+		return 0, l.smb.wrapError("write", l.path, errEncrypted{})
+	}
+	if l.file == nil {
+		// A failed rotation could not reopen the file; try again.
+		if err := l.open(); err != nil {
+			return 0, err
+		}
+	}
+	if l.due(int64(len(p))) {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if err := writeFull(l.file, p); err != nil {
+		return 0, err
+	}
+	l.size += int64(len(p))
+	return len(p), nil
+}
+
+// due reports whether a record of n bytes must go to a new file. An empty
+// file is never rotated, so a record larger than MaxSize still lands.
+func (l *LogWriter) due(n int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	return (l.opts.MaxSize > 0 && l.size+n > l.opts.MaxSize) ||
+		(l.opts.MaxAge > 0 && time.Since(l.opened) >= l.opts.MaxAge)
+}
+
+// Rotate moves the current file aside and starts a new one now.
+func (l *LogWriter) Rotate() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return l.smb.wrapError("rotate", l.path, ErrClosed)
+	}
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	return l.rotate()
+}
+
+// rotate moves the file aside. When that fails the file is reopened for
+// appending, so only the record that triggered the rotation is lost and
+// the next one tries again.
+func (l *LogWriter) rotate() error {
+	err := l.file.Close()
+	l.file = nil
+	rotated := l.path + "." + time.Now().UTC().Format(rotateStamp)
+	if err == nil {
+		err = l.smb.Rename(l.path, rotated)
+	}
+	if oerr := l.open(); err == nil {
+		err = oerr
+	}
+	if err != nil {
+		return err
+	}
+	if l.opts.Compress {
+		return l.compress(rotated)
+	}
+	return nil
+}
+
+// compress replaces rotated with a gzipped copy. While it runs, Writes
+// wait.
+func (l *LogWriter) compress(rotated string) error {
+	in, err := l.smb.open(rotated, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := l.smb.open(rotated+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return removeCopied(l.smb, rotated)
+}
+
+// Close closes the current file.
+func (l *LogWriter) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
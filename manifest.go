@@ -0,0 +1,179 @@
+package libsmb2
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	path2 "path"
+	"strings"
+	"time"
+)
+
+// manifestHashes are the hashes a Manifest can record, by name.
+var manifestHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Manifest lists a tree as it was at Created: every file and directory
+// below Root, by slash-separated path relative to it, in sorted order. It
+// marshals to and from JSON as is.
+type Manifest struct {
+	Root    string          `json:"root"`
+	Created time.Time       `json:"created"`
+	Hash    string          `json:"hash,omitempty"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is a file or directory in a Manifest. Sum is the hex
+// digest of a file's content, empty when the manifest has no hash.
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Sum     string    `json:"sum,omitempty"`
+}
+
+// ManifestDiff is what Verify found different from a Manifest. Changed
+// maps paths to what differs: "type", "size", "sum" or "mtime".
+type ManifestDiff struct {
+	Missing []string          `json:"missing"`
+	Extra   []string          `json:"extra"`
+	Changed map[string]string `json:"changed"`
+}
+
+// OK reports whether the tree matched the manifest.
+func (d *ManifestDiff) OK() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+// Manifest lists the tree at root. hashName is "md5", "sha1", "sha256" or
+// "sha512" to read every file and record its digest, or "" for a listing
+// of sizes and times only.
+func (s *Smb) Manifest(root string, hashName string) (*Manifest, error) {
+	return s.manifest(root, hashName, func(p string, info os.FileInfo) (string, error) {
+		return s.fileSum(p, manifestHashes[hashName]())
+	})
+}
+
+// manifest is Manifest with the digest of each file computed by sum.
+func (s *Smb) manifest(root string, hashName string, sum func(p string, info os.FileInfo) (string, error)) (*Manifest, error) {
+	if _, ok := manifestHashes[hashName]; hashName != "" && !ok {
+		return nil, fmt.Errorf("unknown manifest hash %q", hashName)
+	}
+	m := &Manifest{Root: root, Created: time.Now().UTC(), Hash: hashName}
+	err := s.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := manifestPath(root, p)
+		if rel == "" {
+			return nil
+		}
+		e := ManifestEntry{Path: rel, Dir: info.IsDir(), ModTime: info.ModTime().UTC()}
+		if !e.Dir {
+			e.Size = info.Size()
+			if hashName != "" {
+				if e.Sum, err = sum(p, info); err != nil {
+					return err
+				}
+			}
+		}
+		m.Entries = append(m.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Verify checks the tree at m.Root against m. Files are compared by size
+// and, when m has a hash, by content; without one the modification time
+// is compared instead. The error is for failures to read the tree, the
+// differences are in the returned diff.
+func (s *Smb) Verify(m *Manifest) (*ManifestDiff, error) {
+	newHash := manifestHashes[m.Hash]
+	if m.Hash != "" && newHash == nil {
+		return nil, fmt.Errorf("unknown manifest hash %q", m.Hash)
+	}
+	want := make(map[string]*ManifestEntry, len(m.Entries))
+	for i := range m.Entries {
+		want[m.Entries[i].Path] = &m.Entries[i]
+	}
+	d := &ManifestDiff{Changed: map[string]string{}}
+	err := s.Walk(m.Root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := manifestPath(m.Root, p)
+		if rel == "" {
+			return nil
+		}
+		e := want[rel]
+		if e == nil {
+			d.Extra = append(d.Extra, rel)
+			return nil
+		}
+		delete(want, rel)
+		switch {
+		case e.Dir != info.IsDir():
+			d.Changed[rel] = "type"
+		case e.Dir:
+		case e.Size != info.Size():
+			d.Changed[rel] = "size"
+		case newHash != nil:
+			sum, err := s.fileSum(p, newHash())
+			if err != nil {
+				return err
+			}
+			if sum != e.Sum {
+				d.Changed[rel] = "sum"
+			}
+		case !e.ModTime.Equal(info.ModTime()):
+			d.Changed[rel] = "mtime"
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range m.Entries {
+		if want[e.Path] != nil {
+			d.Missing = append(d.Missing, e.Path)
+		}
+	}
+	return d, nil
+}
+
+// manifestPath returns p relative to root, "" for root itself.
+func manifestPath(root string, p string) string {
+	root = path2.Clean("/" + root)
+	p = path2.Clean("/" + p)
+	if p == root {
+		return ""
+	}
+	return strings.TrimPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// fileSum returns the hex digest of the content of path.
+func (s *Smb) fileSum(path string, h hash.Hash) (string, error) {
+	f, err := s.open(path, os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
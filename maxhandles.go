@@ -0,0 +1,62 @@
+package libsmb2
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTooManyHandles is returned when opening a file would exceed the
+// limit set with SetMaxHandles in fail-fast mode.
+var ErrTooManyHandles = errors.New("too many open handles")
+
+// SetMaxHandles caps the handles the session holds open on the server at
+// once, 0 for no cap. An open over the cap waits for another handle to be
+// closed if wait is set, and fails with ErrTooManyHandles otherwise. Files
+// closed by the idle reaper do not count until they are used again. A
+// program that waits while holding every handle itself will wait forever.
+func (s *Smb) SetMaxHandles(max int, wait bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxHandles = max
+	s.waitHandles = wait
+	if s.handleFreed == nil {
+		s.handleFreed = sync.NewCond(&s.mutex)
+	}
+	s.handleFreed.Broadcast()
+}
+
+// liveHandles counts the server handles held by open files. Must be called
+// with s.mutex held.
+func (s *Smb) liveHandles() int {
+	n := 0
+	for f := range s.files {
+		if f.fd != nil || f.dir != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// reserveHandle makes sure one more handle fits under the cap, waiting
+// for one to be freed if so configured. Must be called with s.mutex held;
+// waiting releases it meanwhile.
+func (s *Smb) reserveHandle(op string, path string) error {
+	for s.maxHandles > 0 && s.liveHandles() >= s.maxHandles {
+		if !s.waitHandles {
+			return s.wrapError(op, path, ErrTooManyHandles)
+		}
+		s.handleFreed.Wait()
+		if s.session == nil {
+			return s.wrapError(op, path, ErrClosed)
+		}
+	}
+	return nil
+}
+
+// handleReleased wakes opens waiting for a handle. Must be called with
+// s.mutex held.
+func (s *Smb) handleReleased() {
+	if s.handleFreed != nil {
+		s.handleFreed.Broadcast()
+	}
+}
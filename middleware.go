@@ -0,0 +1,116 @@
+package libsmb2
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Call describes an operation on its way through the middleware chain.
+// Size is the buffer length for reads and writes and 0 otherwise; Bytes is
+// what they transferred, set once the call returns. Tags are the session's
+// and must not be modified.
+type Call struct {
+	Op    string
+	Path  string
+	Size  int
+	Bytes int
+	Tags  map[string]string
+}
+
+// Invoker runs a call, or the rest of the chain in front of it.
+type Invoker func(c *Call) error
+
+// Middleware wraps an Invoker, much like an http.RoundTripper wraps
+// another. It may run code around next, call it several times, or not at
+// all.
+type Middleware func(next Invoker) Invoker
+
+// Use appends middleware to the chain every operation passes through. The
+// first one added is the outermost. The chain covers connect, open, stat,
+// read, write, seek, readdir, close, fsync, mkdir, remove, rename,
+// statvfs, readlink and echo; the helpers built on them go through it once per
+// primitive. Middleware runs without the session lock held.
+func (s *Smb) Use(mw ...Middleware) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.middleware = append(s.middleware, mw...)
+}
+
+// invoke runs call through the chain. It must be called without s.mutex
+// held; call takes the lock itself.
+func (s *Smb) invoke(op string, path string, size int, call func() error) error {
+	return s.invokeN(op, path, size, func() (int, error) { return 0, call() })
+}
+
+// invokeN is invoke for calls that transfer data, recording the count in
+// Call.Bytes.
+func (s *Smb) invokeN(op string, path string, size int, call func() (int, error)) error {
+	s.mutex.Lock()
+	if s.draining && op != "close" {
+		s.mutex.Unlock()
+		return s.wrapError(op, path, ErrClosed)
+	}
+	s.inflight++
+	defer s.done()
+	chain, tags := s.middleware, s.tags
+	priority, sched := s.priority, s.scheduler
+	s.mutex.Unlock()
+	if priority != nil && op != "close" {
+		class, inner := priority(&Call{Op: op, Path: path, Size: size, Tags: tags}), call
+		call = func() (int, error) {
+			sched.acquire(class)
+			defer sched.release()
+			return inner()
+		}
+	}
+	if len(chain) == 0 {
+		_, err := call()
+		return err
+	}
+	next := Invoker(func(c *Call) (err error) {
+		c.Bytes, err = call()
+		return
+	})
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+	return next(&Call{Op: op, Path: path, Size: size, Tags: tags})
+}
+
+// done ends an operation counted by invokeN, waking a draining Shutdown
+// once it was the last. It must be called without s.mutex held.
+func (s *Smb) done() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.inflight--; s.inflight == 0 && s.idle != nil {
+		close(s.idle)
+		s.idle = nil
+	}
+}
+
+// Retry returns middleware that runs a call up to attempts times while it
+// fails with a transient error, sleeping backoff before the first retry
+// and doubling it after each. It does not know whether a write reached
+// the server before failing, so put it only on sessions whose callers can
+// tolerate a repeated operation.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next Invoker) Invoker {
+		return func(c *Call) error {
+			err := next(c)
+			for i, wait := 1, backoff; i < attempts && Transient(err); i, wait = i+1, wait*2 {
+				time.Sleep(wait)
+				err = next(c)
+			}
+			return err
+		}
+	}
+}
+
+// Transient reports whether err is one that a retry can be expected to
+// get past: a timeout, a sharing violation or a server short on resources.
+func Transient(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, StatusSharingViolation) ||
+		errors.Is(err, StatusInsuffServerResources)
+}
@@ -0,0 +1,36 @@
+package libsmb2
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	path2 "path"
+)
+
+// sniffLen is what http.DetectContentType looks at.
+const sniffLen = 512
+
+// DetectContentType returns the MIME type of path for a Content-Type
+// header. It sniffs the first 512 bytes, fetched in a single read, and
+// falls back on the file extension when sniffing only finds generic text
+// or binary data, as for CSS, JavaScript or JSON.
+func (s *Smb) DetectContentType(path string) (string, error) {
+	r, err := s.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	ctype := http.DetectContentType(buf[:n])
+	switch ctype {
+	case "application/octet-stream", "text/plain; charset=utf-8":
+		if byExt := mime.TypeByExtension(path2.Ext(path)); byExt != "" {
+			return byExt, nil
+		}
+	}
+	return ctype, nil
+}
@@ -0,0 +1,59 @@
+package libsmb2
+
+import (
+	"errors"
+	path2 "path"
+)
+
+// Move moves srcPath on src to dstPath on dst, picking the cheapest
+// strategy: a rename when both are on the same session, otherwise a copy
+// followed by removing the source. A rename the server refuses across
+// volumes (STATUS_NOT_SAME_DEVICE) also falls back to the copy.
+// Directories are moved recursively. Server side copy-chunk is not
+// reachable through libsmb2, so cross share moves stream the data through
+// the client.
+func Move(src *Smb, srcPath string, dst *Smb, dstPath string) error {
+	if src == dst {
+		err := src.Rename(srcPath, dstPath)
+		if !errors.Is(err, StatusNotSameDevice) {
+			return err
+		}
+	}
+	return copyAndRemove(src, srcPath, dst, dstPath)
+}
+
+func copyAndRemove(src *Smb, srcPath string, dst *Smb, dstPath string) error {
+	fi, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		if _, err := CopyBetween(src, srcPath, dst, dstPath, nil); err != nil {
+			return err
+		}
+		return removeCopied(src, srcPath)
+	}
+	if err := dst.MkdirAll(dstPath); err != nil {
+		return err
+	}
+	src.mutex.Lock()
+	names, err := src.readDirNames(srcPath)
+	src.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := copyAndRemove(src, path2.Join(srcPath, name), dst, path2.Join(dstPath, name)); err != nil {
+			return err
+		}
+	}
+	return removeCopied(src, srcPath)
+}
+
+// removeCopied deletes a file whose data lives elsewhere, like a copied
+// source or a temporary object, bypassing the recycle folder.
+func removeCopied(s *Smb, path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.remove(path)
+}
@@ -0,0 +1,220 @@
+package libsmb2
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// minLeaseTTL is the shortest TTL a Mutex uses.
+const minLeaseTTL = time.Second
+
+// ErrLockLost is returned by Unlock when the lease was broken or taken
+// over while held, for instance after heartbeats failed for a whole TTL.
+var ErrLockLost = errors.New("lock lost")
+
+// Mutex is a cooperative lock shared by hosts through a lease file on a
+// share. The holder creates the file exclusively and renews its expiry
+// every TTL/3; a file whose lease ran out is considered abandoned and may
+// be broken by the next contender. It only excludes programs that use it
+// too, and relies on the hosts' clocks agreeing to within a fraction of
+// the TTL.
+type Mutex struct {
+	smb  *Smb
+	path string
+	ttl  time.Duration
+
+	mutex sync.Mutex
+	token string
+	stop  chan struct{}
+	lost  chan struct{}
+	done  sync.WaitGroup
+}
+
+type lease struct {
+	Owner   string    `json:"owner"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewMutex returns a lock on the lease file path with the given TTL. A
+// TTL under a second, which clocks could not agree on anyway, is raised
+// to one second.
+func NewMutex(s *Smb, path string, ttl time.Duration) *Mutex {
+	if ttl < minLeaseTTL {
+		ttl = minLeaseTTL
+	}
+	return &Mutex{smb: s, path: path, ttl: ttl}
+}
+
+// Lock takes the lock, polling until it is free or ctx is done.
+func (m *Mutex) Lock(ctx context.Context) error {
+	poll := m.ttl / 10
+	if poll < 100*time.Millisecond {
+		poll = 100 * time.Millisecond
+	}
+	for {
+		ok, err := m.TryLock()
+		if ok || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// TryLock takes the lock if it is free or abandoned and reports whether it
+// did.
+func (m *Mutex) TryLock() (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.token != "" {
+		return false, errors.New("lock already held")
+	}
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return false, err
+	}
+	token := hex.EncodeToString(raw[:])
+	err := m.write(token, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if errors.Is(err, os.ErrExist) {
+		cur, rerr := m.read()
+		if rerr == nil && time.Now().After(cur.Expires) {
+			// Abandoned: break it, and take it on the next attempt.
+			if again, aerr := m.read(); aerr == nil && again.Token == cur.Token {
+				removeCopied(m.smb, m.path)
+			}
+		} else if rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			m.breakUnreadable()
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	m.token = token
+	m.stop = make(chan struct{})
+	m.lost = make(chan struct{})
+	m.done.Add(1)
+	go m.heartbeat(token, m.stop, m.lost)
+	return true, nil
+}
+
+// breakUnreadable removes a lease file that cannot be read back, empty
+// after a crash between its creation and the write of the lease, once it
+// has not been written for a whole TTL. A live holder rewrites it every
+// TTL/3, so a torn read of a held lease always finds a recent one.
+func (m *Mutex) breakUnreadable() {
+	info, err := m.smb.Stat(m.path)
+	if err != nil || time.Since(info.ModTime()) < m.ttl {
+		return
+	}
+	if again, err := m.smb.Stat(m.path); err == nil && again.ModTime().Equal(info.ModTime()) {
+		removeCopied(m.smb, m.path)
+	}
+}
+
+// Lost is closed when the lease of the held lock could not be renewed
+// before it expired or was found taken over; the holder should stop
+// relying on the lock.
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lost
+}
+
+// Unlock releases the lock, removing the lease file if it still holds it.
+func (m *Mutex) Unlock() error {
+	m.mutex.Lock()
+	token, stop, lost := m.token, m.stop, m.lost
+	m.token = ""
+	m.mutex.Unlock()
+	if token == "" {
+		return errors.New("lock not held")
+	}
+	close(stop)
+	m.done.Wait()
+	select {
+	case <-lost:
+		return m.smb.wrapError("unlock", m.path, ErrLockLost)
+	default:
+	}
+	if cur, err := m.read(); err != nil || cur.Token != token {
+		return m.smb.wrapError("unlock", m.path, ErrLockLost)
+	}
+	return removeCopied(m.smb, m.path)
+}
+
+func (m *Mutex) heartbeat(token string, stop chan struct{}, lost chan struct{}) {
+	defer m.done.Done()
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		cur, err := m.read()
+		if err == nil && cur.Token != token {
+			close(lost)
+			return
+		}
+		if err == nil {
+			err = m.write(token, os.O_WRONLY|os.O_TRUNC)
+		}
+		if err == nil {
+			renewed = time.Now()
+		} else if time.Since(renewed) >= m.ttl {
+			close(lost)
+			return
+		}
+	}
+}
+
+// write stores a lease for token, opening the file with flags.
+func (m *Mutex) write(token string, flags int) error {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(lease{
+		Owner:   fmt.Sprintf("%s/%d", host, os.Getpid()),
+		Token:   token,
+		Expires: time.Now().Add(m.ttl).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	f, err := m.smb.open(m.path, flags)
+	if err != nil {
+		return err
+	}
+	return writeAndClose(f, bytes.NewReader(data))
+}
+
+func (m *Mutex) read() (*lease, error) {
+	f, err := m.smb.open(m.path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var l lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
@@ -0,0 +1,113 @@
+package libsmb2
+
+import "strings"
+
+// NameMapping controls how names are translated between the caller and
+// the share.
+type NameMapping struct {
+	// Normalize is applied to every path before it is sent to the server,
+	// typically norm.NFC.String from golang.org/x/text/unicode/norm when
+	// syncing from macOS, whose file systems hand out NFD names.
+	Normalize func(string) string
+	// SFM maps characters that are invalid in SMB names (control
+	// characters, `"*:<>?\|` and a trailing space or dot) to the private
+	// use code points used by Services for Macintosh and by Samba's catia
+	// and fruit modules. Names read from the server are mapped back.
+	SFM bool
+}
+
+// SetNameMapping installs m on the session. The zero NameMapping sends
+// names unchanged.
+func (s *Smb) SetNameMapping(m NameMapping) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.names = m
+}
+
+const (
+	sfmBase          = 0xF000
+	sfmTrailingSpace = 0xF028
+	sfmTrailingDot   = 0xF029
+)
+
+var sfmChars = map[rune]rune{
+	'"':  0xF020,
+	'*':  0xF021,
+	':':  0xF022,
+	'<':  0xF023,
+	'>':  0xF024,
+	'?':  0xF025,
+	'\\': 0xF026,
+	'|':  0xF027,
+}
+
+var sfmReverse = func() map[rune]rune {
+	m := map[rune]rune{
+		sfmTrailingSpace: ' ',
+		sfmTrailingDot:   '.',
+	}
+	for c, mapped := range sfmChars {
+		m[mapped] = c
+	}
+	for c := rune(0x01); c < 0x20; c++ {
+		m[sfmBase+c] = c
+	}
+	return m
+}()
+
+// serverPath translates a caller supplied path for the server. Must be
+// called with s.mutex held.
+func (s *Smb) serverPath(path string) string {
+	if s.names.Normalize != nil {
+		path = s.names.Normalize(path)
+	}
+	if !s.names.SFM {
+		return path
+	}
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = sfmEncode(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// clientName translates a name read from the server back for the caller.
+// Must be called with s.mutex held.
+func (s *Smb) clientName(name string) string {
+	if !s.names.SFM {
+		return name
+	}
+	return sfmDecode(name)
+}
+
+func sfmEncode(name string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+	runes := []rune(name)
+	for i, c := range runes {
+		if mapped, ok := sfmChars[c]; ok {
+			runes[i] = mapped
+		} else if c > 0 && c < 0x20 {
+			runes[i] = sfmBase + c
+		}
+	}
+	if last := len(runes) - 1; last >= 0 {
+		switch runes[last] {
+		case ' ':
+			runes[last] = sfmTrailingSpace
+		case '.':
+			runes[last] = sfmTrailingDot
+		}
+	}
+	return string(runes)
+}
+
+func sfmDecode(name string) string {
+	return strings.Map(func(c rune) rune {
+		if orig, ok := sfmReverse[c]; ok {
+			return orig
+		}
+		return c
+	}, name)
+}
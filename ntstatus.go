@@ -0,0 +1,73 @@
+package libsmb2
+
+import (
+	"fmt"
+	"os"
+)
+
+// NTStatus is an NT status code as returned by SMB2 servers.
+type NTStatus uint32
+
+const (
+	StatusSuccess                NTStatus = 0x00000000
+	StatusEndOfFile              NTStatus = 0xC0000011
+	StatusAccessDenied           NTStatus = 0xC0000022
+	StatusObjectNameNotFound     NTStatus = 0xC0000034
+	StatusObjectNameCollision    NTStatus = 0xC0000035
+	StatusObjectPathNotFound     NTStatus = 0xC000003A
+	StatusSharingViolation       NTStatus = 0xC0000043
+	StatusLogonFailure           NTStatus = 0xC000006D
+	StatusDiskFull               NTStatus = 0xC000007F
+	StatusIoTimeout              NTStatus = 0xC00000B5
+	StatusFileIsADirectory       NTStatus = 0xC00000BA
+	StatusNetworkNameDeleted     NTStatus = 0xC00000C9
+	StatusNotSameDevice          NTStatus = 0xC00000D4
+	StatusDirectoryNotEmpty      NTStatus = 0xC0000101
+	StatusNotADirectory          NTStatus = 0xC0000103
+	StatusInsuffServerResources  NTStatus = 0xC0000205
+	StatusConnectionDisconnected NTStatus = 0xC000020C
+	StatusConnectionReset        NTStatus = 0xC000020D
+)
+
+var ntStatusNames = map[NTStatus]string{
+	StatusSuccess:                "STATUS_SUCCESS",
+	StatusEndOfFile:              "STATUS_END_OF_FILE",
+	StatusAccessDenied:           "STATUS_ACCESS_DENIED",
+	StatusObjectNameNotFound:     "STATUS_OBJECT_NAME_NOT_FOUND",
+	StatusObjectNameCollision:    "STATUS_OBJECT_NAME_COLLISION",
+	StatusObjectPathNotFound:     "STATUS_OBJECT_PATH_NOT_FOUND",
+	StatusSharingViolation:       "STATUS_SHARING_VIOLATION",
+	StatusLogonFailure:           "STATUS_LOGON_FAILURE",
+	StatusDiskFull:               "STATUS_DISK_FULL",
+	StatusIoTimeout:              "STATUS_IO_TIMEOUT",
+	StatusFileIsADirectory:       "STATUS_FILE_IS_A_DIRECTORY",
+	StatusNetworkNameDeleted:     "STATUS_NETWORK_NAME_DELETED",
+	StatusNotSameDevice:          "STATUS_NOT_SAME_DEVICE",
+	StatusDirectoryNotEmpty:      "STATUS_DIRECTORY_NOT_EMPTY",
+	StatusNotADirectory:          "STATUS_NOT_A_DIRECTORY",
+	StatusInsuffServerResources:  "STATUS_INSUFF_SERVER_RESOURCES",
+	StatusConnectionDisconnected: "STATUS_CONNECTION_DISCONNECTED",
+	StatusConnectionReset:        "STATUS_CONNECTION_RESET",
+}
+
+func (e NTStatus) Error() string {
+	if name, ok := ntStatusNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("NT status 0x%08x", uint32(e))
+}
+
+// Is lets errors.Is match NT statuses against the os error sentinels.
+func (e NTStatus) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		return e == StatusObjectNameNotFound || e == StatusObjectPathNotFound
+	case os.ErrExist:
+		return e == StatusObjectNameCollision
+	case os.ErrPermission:
+		return e == StatusAccessDenied
+	case os.ErrDeadlineExceeded:
+		return e == StatusIoTimeout
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package libsmb2
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"os"
+	"sort"
+)
+
+// ErrBadToken is returned by ReadDirPage for a token it did not issue.
+var ErrBadToken = errors.New("malformed enumeration token")
+
+const tokenPrefix = "n1."
+
+// ReadDirPage returns up to n entries of dir in name order, starting after
+// the position token records; "" starts at the beginning. next is the
+// token for the following page, "" once the listing is through. Tokens
+// are plain strings meant to be persisted, so an indexer can carry on
+// after a restart where it stopped.
+//
+// A token records the last name returned, not a server cursor, which
+// libsmb2 does not expose: entries created behind it are not seen, and
+// every page lists the directory again on the wire. Entries are streamed
+// as they come and only the page being built is kept as FileInfos, so a
+// directory with millions of entries never has them all in Go memory;
+// libsmb2 still holds its raw listing while the handle is open.
+func (s *Smb) ReadDirPage(dir string, token string, n int) (entries []os.FileInfo, next string, err error) {
+	after := ""
+	if token != "" {
+		if len(token) < len(tokenPrefix) || token[:len(tokenPrefix)] != tokenPrefix {
+			return nil, "", s.wrapError("readdir", dir, ErrBadToken)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(token[len(tokenPrefix):])
+		if err != nil {
+			return nil, "", s.wrapError("readdir", dir, ErrBadToken)
+		}
+		after = string(raw)
+	}
+	// Keep the n+1 smallest names after the token, one more than the
+	// page to know whether another follows, in a max-heap by name.
+	var page pageHeap
+	s.mutex.Lock()
+	err = s.scanDir(dir, func(info os.FileInfo) {
+		if (token != "" && info.Name() <= after) || (s.hidden != nil && s.hidden(info.Name())) {
+			return
+		}
+		if n <= 0 || len(page) <= n {
+			heap.Push(&page, info)
+		} else if info.Name() < page[0].Name() {
+			page[0] = info
+			heap.Fix(&page, 0)
+		}
+	})
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(page, func(i, j int) bool { return page[i].Name() < page[j].Name() })
+	entries = page
+	if n > 0 && len(page) > n {
+		entries = page[:n]
+		next = tokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(page[n-1].Name()))
+	}
+	return entries, next, nil
+}
+
+// pageHeap is a max-heap of entries by name.
+type pageHeap []os.FileInfo
+
+func (h pageHeap) Len() int           { return len(h) }
+func (h pageHeap) Less(i, j int) bool { return h[i].Name() > h[j].Name() }
+func (h pageHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pageHeap) Push(x any)        { *h = append(*h, x.(os.FileInfo)) }
+func (h *pageHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
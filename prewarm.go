@@ -0,0 +1,69 @@
+package libsmb2
+
+import "time"
+
+// Prewarm opens paths ahead of use and keeps their handles open, so the
+// first OpenRange of a hot asset pays no open round trip either. libsmb2
+// has no leases to learn of changes, so an open at least revalidate after
+// the last check stats the file and, when its ETag changed, serves from a
+// fresh handle; a zero revalidate never checks. Existing readers keep the
+// handle they have. Opening errors are returned for the first path that
+// fails; the paths before it stay warm. Every warm handle holds its
+// libsmb2 state until Cool releases it.
+func (s *Smb) Prewarm(revalidate time.Duration, paths ...string) error {
+	s.rangeMutex.Lock()
+	defer s.rangeMutex.Unlock()
+	s.prewarmCheck = revalidate
+	for _, path := range paths {
+		h, err := s.acquireRange(path)
+		if err != nil {
+			return err
+		}
+		if h.pinned {
+			s.releaseRange(h)
+			continue
+		}
+		h.pinned, h.etag, h.checked = true, ETag(h.file), time.Now()
+	}
+	return nil
+}
+
+// Cool releases the handles Prewarm keeps for paths. They close once
+// their last reader is done and the linger time has passed.
+func (s *Smb) Cool(paths ...string) {
+	s.rangeMutex.Lock()
+	defer s.rangeMutex.Unlock()
+	for _, path := range paths {
+		if h := s.ranges[path]; h != nil && h.pinned {
+			h.pinned = false
+			s.releaseRange(h)
+		}
+	}
+}
+
+// revalidate checks a pinned handle that is due and returns the handle to
+// use, or nil when the file is gone. Must be called with s.rangeMutex
+// held.
+func (s *Smb) revalidate(h *sharedHandle) *sharedHandle {
+	if s.prewarmCheck <= 0 || time.Since(h.checked) < s.prewarmCheck {
+		return h
+	}
+	h.checked = time.Now()
+	info, err := s.Stat(h.path)
+	if err == nil && ETag(info) == h.etag {
+		return h
+	}
+	delete(s.ranges, h.path)
+	h.pinned = false
+	s.releaseRange(h)
+	if err != nil {
+		return nil
+	}
+	f, err := s.OpenFile(h.path, 0)
+	if err != nil {
+		return nil
+	}
+	fresh := &sharedHandle{path: h.path, file: f, refs: 1, pinned: true, etag: ETag(f), checked: time.Now()}
+	s.ranges[h.path] = fresh
+	return fresh
+}
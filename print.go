@@ -0,0 +1,21 @@
+package libsmb2
+
+import (
+	"io"
+	"os"
+)
+
+// Print submits everything read from r as a print job named doc. The
+// session must be connected to a printer share. The job is spooled the way
+// Windows clients do it: doc is created on the queue, the data is written
+// and closing the handle releases the job to the printer, so r must
+// already be in a format the printer or its driver accepts (raw ZPL, PCL,
+// PostScript...). Printers cannot read sealed content, so Print fails on a
+// session with encryption set.
+func (s *Smb) Print(doc string, r io.Reader) error {
+	f, err := s.OpenFile(doc, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	return writeAndClose(f, r)
+}
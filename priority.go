@@ -0,0 +1,100 @@
+package libsmb2
+
+import (
+	"sync"
+)
+
+// Priority is the scheduling class of a call.
+type Priority int
+
+const (
+	// Interactive calls go ahead of waiting background calls.
+	Interactive Priority = iota
+	// Background calls run when no interactive call is waiting.
+	Background
+)
+
+// backgroundShare is how many interactive calls may overtake waiting
+// background calls before one of those is let through, so bulk traffic
+// slows down under interactive load but never stops.
+const backgroundShare = 8
+
+// PriorityFunc picks the class of a call.
+type PriorityFunc func(c *Call) Priority
+
+// DataBackground is a PriorityFunc that puts reads and writes in the
+// background and everything else, the metadata lookups a user waits on,
+// ahead of them.
+func DataBackground(c *Call) Priority {
+	if c.Op == "read" || c.Op == "write" {
+		return Background
+	}
+	return Interactive
+}
+
+// SetPriority makes the session run its calls one at a time in the order
+// of the classes fn assigns, so bulk transfers sharing the connection do
+// not starve interactive lookups: a waiting interactive call goes before
+// any waiting background call, except that one background call gets
+// through after every eight interactive ones. Calls of the same class run
+// in no particular order. Close is never held back. Helpers that list
+// directories for Walk take the session lock directly and are not
+// scheduled. nil turns scheduling off.
+func (s *Smb) SetPriority(fn PriorityFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.priority = fn
+	if fn != nil && s.scheduler == nil {
+		s.scheduler = newScheduler()
+	}
+}
+
+// scheduler admits one call at a time by priority.
+type scheduler struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	busy     bool
+	waiting  [2]int
+	overtook int
+}
+
+func newScheduler() *scheduler {
+	q := &scheduler{}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *scheduler) acquire(p Priority) {
+	if p != Interactive {
+		p = Background
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.waiting[p]++
+	for q.busy || !q.turn(p) {
+		q.cond.Wait()
+	}
+	q.waiting[p]--
+	q.busy = true
+	if p == Interactive && q.waiting[Background] > 0 {
+		q.overtook++
+	} else if p == Background {
+		q.overtook = 0
+	}
+}
+
+// turn reports whether a call of class p may go next. Must be called with
+// q.mutex held.
+func (q *scheduler) turn(p Priority) bool {
+	if p == Interactive {
+		return q.waiting[Background] == 0 || q.overtook < backgroundShare
+	}
+	return q.waiting[Interactive] == 0 || q.overtook >= backgroundShare
+}
+
+func (q *scheduler) release() {
+	q.mutex.Lock()
+	q.busy = false
+	q.mutex.Unlock()
+	q.cond.Broadcast()
+}
@@ -0,0 +1,66 @@
+package libsmb2
+
+import (
+	"sync"
+	"time"
+)
+
+// Quota returns middleware that holds a session to opsPerSecond
+// operations and bytesPerSecond of reads and writes, a zero leaving that
+// side unlimited. Each allows a burst of one second's worth. Calls over
+// the quota wait rather than fail, so a caller sharing the server with
+// others simply slows down. Reads and writes are charged their requested
+// size up front. Install one per session; sharing the middleware among
+// sessions makes them share the quota.
+func Quota(opsPerSecond float64, bytesPerSecond float64) Middleware {
+	ops := newBucket(opsPerSecond)
+	bytes := newBucket(bytesPerSecond)
+	return func(next Invoker) Invoker {
+		return func(c *Call) error {
+			wait := ops.take(1)
+			if c.Op == "read" || c.Op == "write" {
+				if d := bytes.take(float64(c.Size)); d > wait {
+					wait = d
+				}
+			}
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+			return next(c)
+		}
+	}
+}
+
+// bucket is a token bucket refilled at rate per second. A take larger than
+// what is left drives it into debt that later takes wait off.
+type bucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64) *bucket {
+	return &bucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// take removes n tokens and returns how long the caller must wait for
+// them to have been there.
+func (b *bucket) take(n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,154 @@
+package libsmb2
+
+import (
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+//#include "libsmb2go.h"
+import "C"
+
+// rangeLinger is how long OpenRange keeps a handle open after its last
+// user closed it.
+const rangeLinger = 30 * time.Second
+
+// ReadAt reads len(p) bytes at off without moving the file position, so
+// concurrent range requests can share one handle.
+func (f *smbFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) && err == nil {
+		var m int
+		err = f.smb.invokeN("read", f.path, len(p)-n, func() (int, error) {
+			m, err = f.readAt(p[n:], off+int64(n))
+			return m, err
+		})
+		n += m
+	}
+	return
+}
+
+func (f *smbFile) readAt(p []byte, off int64) (n int, err error) {
+	f.smb.mutex.Lock()
+	defer f.smb.mutex.Unlock()
+	if err = f.revive(); err != nil {
+		return 0, err
+	}
+	if f.fd == nil || f.smb.session == nil {
+		return 0, f.smb.wrapError("read", f.path, ErrClosed)
+	}
+	defer func(start time.Time) { f.smb.logSlow("read", f.path, int64(n), start) }(time.Now())
+	if err = f.smb.injectFault("read", f.path, len(p)); err != nil {
+		return 0, f.smb.wrapError("read", f.path, err)
+	}
+	var restore func()
+	if restore, err = f.applyDeadline("read"); err != nil {
+		return 0, err
+	}
+	defer restore()
+	var cerr cError
+	n = int(C.smb2_read_wrapper(f.smb.session, f.fd, unsafe.Pointer(&p[0]), C.ulong(len(p)), C.longlong(off), cerr.ptr(), cerr.len()))
+	if n < 0 {
+		return 0, f.smb.newError("read", f.path, n, cerr.String())
+	} else if n == 0 {
+		return 0, io.EOF
+	}
+	f.bytesRead += int64(n)
+	f.used = time.Now()
+	return n, nil
+}
+
+// RangeFile serves random reads of one file, as HTTP range requests for
+// video seeking do. It reads only what is asked, at the offset asked,
+// with no read-ahead.
+type RangeFile struct {
+	*io.SectionReader
+	shared *sharedHandle
+	once   sync.Once
+}
+
+type sharedHandle struct {
+	path  string
+	file  *smbFile
+	refs  int
+	timer *time.Timer
+	// pinned handles hold a reference of their own, see Prewarm.
+	pinned  bool
+	etag    string
+	checked time.Time
+}
+
+// OpenRange opens path for range reads. Concurrent and successive opens of
+// the same path share one handle, kept open for 30 seconds after the last
+// RangeFile is closed, so a player seeking around needs no new open per
+// request. The size is that of the first open while the handle lives.
+func (s *Smb) OpenRange(path string) (*RangeFile, error) {
+	s.rangeMutex.Lock()
+	defer s.rangeMutex.Unlock()
+	h, err := s.acquireRange(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RangeFile{
+		SectionReader: io.NewSectionReader(h.file, 0, h.file.Size()),
+		shared:        h,
+	}, nil
+}
+
+// acquireRange returns the shared handle of path with a reference taken,
+// opening it if needed. Must be called with s.rangeMutex held.
+func (s *Smb) acquireRange(path string) (*sharedHandle, error) {
+	h := s.ranges[path]
+	if h != nil && h.pinned {
+		h = s.revalidate(h)
+	}
+	if h == nil {
+		f, err := s.OpenFile(path, 0)
+		if err != nil {
+			return nil, err
+		}
+		h = &sharedHandle{path: path, file: f}
+		if s.ranges == nil {
+			s.ranges = make(map[string]*sharedHandle)
+		}
+		s.ranges[path] = h
+	}
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	h.refs++
+	return h, nil
+}
+
+// releaseRange drops a reference to h. The last one closes it after the
+// linger time, or at once if h was replaced meanwhile. Must be called
+// with s.rangeMutex held.
+func (s *Smb) releaseRange(h *sharedHandle) {
+	if h.refs--; h.refs > 0 {
+		return
+	}
+	if s.ranges[h.path] != h {
+		h.file.Close()
+		return
+	}
+	h.timer = time.AfterFunc(rangeLinger, func() {
+		s.rangeMutex.Lock()
+		defer s.rangeMutex.Unlock()
+		if h.refs == 0 && s.ranges[h.path] == h {
+			delete(s.ranges, h.path)
+			h.file.Close()
+		}
+	})
+}
+
+// Close releases the shared handle.
+func (r *RangeFile) Close() error {
+	r.once.Do(func() {
+		s := r.shared.file.smb
+		s.rangeMutex.Lock()
+		defer s.rangeMutex.Unlock()
+		s.releaseRange(r.shared)
+	})
+	return nil
+}
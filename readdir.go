@@ -0,0 +1,22 @@
+package libsmb2
+
+// ReaddirOptions controls how Readdir pages through a directory.
+type ReaddirOptions struct {
+	// Snapshot makes the first Readdir call on a directory read the whole
+	// listing in one pass over its handle and close it; that and later
+	// calls then page through the copy. Entries created or removed while
+	// the caller is paging can then neither repeat nor go missing, at the
+	// cost of holding the listing in memory.
+	Snapshot bool
+	// Sort orders a snapshot by name. It has no effect without Snapshot,
+	// as entries are otherwise returned in server order as they arrive.
+	Sort bool
+}
+
+// SetReaddirOptions sets the options for Readdir on directories opened
+// afterwards and on those not yet read.
+func (s *Smb) SetReaddirOptions(opts ReaddirOptions) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readdir = opts
+}
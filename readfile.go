@@ -0,0 +1,51 @@
+package libsmb2
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// ReadFile returns the whole content of path. A file that fits in one read
+// is read with a single request one byte larger than its size: the short
+// reply already says it is at its end, saving the extra read that reading
+// until EOF would spend. The open still costs its create, the query for
+// the size and the close, so a small file takes four round trips rather
+// than five.
+func (s *Smb) ReadFile(path string) ([]byte, error) {
+	if s.layered() {
+		r, err := s.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	f, err := s.open(path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size := f.Size()
+	if max := int64(s.Limits().MaxReadSize); size < 0 || size >= max {
+		return io.ReadAll(f)
+	}
+	buf := make([]byte, size+1)
+	n, err := f.Read(buf)
+	if err == io.EOF {
+		return buf[:0], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) <= size {
+		return buf[:n], nil
+	}
+	// The file grew since it was opened; read the rest the slow way.
+	var out bytes.Buffer
+	out.Write(buf[:n])
+	if _, err := out.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package libsmb2
+
+import (
+	"os"
+	"time"
+	"unsafe"
+)
+
+//#include <stdlib.h>
+//#include "libsmb2go.h"
+import "C"
+
+// SetIdleTimeout closes file handles left unused for longer than d, for
+// servers with a low limit on open files and programs that forget to
+// close theirs. A closed file stays valid: its next read, write or seek
+// opens it again, without the create and truncate flags, at the same
+// position. Directory handles are not reaped, since a listing cannot be
+// resumed. A zero d stops the reaper.
+func (s *Smb) SetIdleTimeout(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopReaper()
+	s.idleTimeout = d
+	if d <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	s.reaperStop = stop
+	go s.reap(d, stop)
+}
+
+// stopReaper ends the reaper goroutine, if any. Must be called with
+// s.mutex held.
+func (s *Smb) stopReaper() {
+	if s.reaperStop != nil {
+		close(s.reaperStop)
+		s.reaperStop = nil
+	}
+}
+
+func (s *Smb) reap(d time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(d / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			if s.session != nil {
+				for f := range s.files {
+					if f.fd != nil && now.Sub(f.used) > d {
+						C.smb2_close(s.session, f.fd)
+						f.fd = nil
+						f.reaped = true
+					}
+				}
+				s.handleReleased()
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// revive reopens a file closed by the reaper. Must be called with the
+// session mutex held.
+func (f *smbFile) revive() error {
+	if !f.reaped || f.smb.session == nil {
+		return nil
+	}
+	cPath, err := f.smb.cPath("open", f.path)
+	if err != nil {
+		return err
+	}
+	defer C.free(unsafe.Pointer(cPath))
+	if err := f.smb.reserveHandle("open", f.path); err != nil {
+		return err
+	}
+	if !f.reaped {
+		return nil
+	}
+	flags := f.flags &^ (os.O_CREATE | os.O_TRUNC | os.O_EXCL)
+	var cerr cError
+	fd := C.smb2_open_wrapper(f.smb.session, cPath, C.int(flags), cerr.ptr(), cerr.len())
+	if fd == nil {
+		return withAccess(f.smb.newError("open", f.path, 0, cerr.String()), accessForFlags(flags))
+	}
+	f.fd = fd
+	f.reaped = false
+	f.used = time.Now()
+	return nil
+}
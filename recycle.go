@@ -0,0 +1,65 @@
+package libsmb2
+
+import (
+	"errors"
+	"os"
+	path2 "path"
+	"strings"
+	"time"
+)
+
+// recycleStamp names the per-deletion folder inside the recycle directory.
+const recycleStamp = "20060102T150405.000000000Z"
+
+// SetRecycleDir makes Remove and RemoveAll move entries into dir instead of
+// deleting them. Every deletion lands in dir/<UTC timestamp>/<path>, which
+// keeps the structure relative to the share root and keeps repeated
+// deletions of one path apart. Entries already inside dir are deleted for
+// real, which is how the recycle folder is emptied. An empty dir restores
+// permanent deletion.
+func (s *Smb) SetRecycleDir(dir string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recycleDir = strings.Trim(dir, "/")
+}
+
+// recycles reports whether removing path should go to the recycle
+// directory. Must be called with s.mutex held.
+func (s *Smb) recycles(path string) bool {
+	if s.recycleDir == "" {
+		return false
+	}
+	path = strings.Trim(path, "/")
+	if len(path) < len(s.recycleDir) || !strings.EqualFold(path[:len(s.recycleDir)], s.recycleDir) {
+		return true
+	}
+	return len(path) != len(s.recycleDir) && path[len(s.recycleDir)] != '/'
+}
+
+// recycle moves path into the recycle directory. Unless all is set, a
+// non-empty directory is refused like Remove would. Must be called with
+// s.mutex held.
+func (s *Smb) recycle(path string, all bool) error {
+	path = strings.Trim(path, "/")
+	fi, err := s.stat(path)
+	if err != nil {
+		if all && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if fi.IsDir() && !all {
+		names, err := s.readDirNames(path)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return s.wrapError("remove", path, StatusDirectoryNotEmpty)
+		}
+	}
+	dest := path2.Join(s.recycleDir, time.Now().UTC().Format(recycleStamp), path)
+	if err := s.mkdirAll(path2.Dir(dest)); err != nil {
+		return err
+	}
+	return s.rename(path, dest)
+}
@@ -0,0 +1,176 @@
+package libsmb2
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReplicaSet reads from one of several servers holding the same data,
+// such as DFS-R members, in order of preference. A read that fails on the
+// current replica is retried on the next, which then stays current. Errors
+// that are answers about the data rather than the server (a missing file,
+// denied access) are returned as they are.
+//
+// The sessions are connected by the caller; the set does not reconnect
+// them. Writes are not replicated and should go to the primary directly.
+type ReplicaSet struct {
+	// FailbackAfter, when set, moves back to the first replica once that
+	// long has passed since failing over from it.
+	FailbackAfter time.Duration
+
+	replicas []*Smb
+	mutex    sync.Mutex
+	current  int
+	failedAt time.Time
+}
+
+// NewReplicaSet returns a set over replicas, the first being preferred.
+func NewReplicaSet(replicas ...*Smb) *ReplicaSet {
+	return &ReplicaSet{replicas: replicas}
+}
+
+// Current returns the replica reads currently go to.
+func (r *ReplicaSet) Current() *Smb {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.replicas[r.pick()]
+}
+
+// pick applies failback and returns the current index. Must be called
+// with r.mutex held.
+func (r *ReplicaSet) pick() int {
+	if r.current != 0 && r.FailbackAfter > 0 && time.Since(r.failedAt) >= r.FailbackAfter {
+		r.current = 0
+	}
+	return r.current
+}
+
+// failover moves past replica i, unless another caller already has.
+func (r *ReplicaSet) failover(i int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.current == i {
+		r.current = (i + 1) % len(r.replicas)
+		if i == 0 {
+			r.failedAt = time.Now()
+		}
+	}
+}
+
+// do runs fn on the current replica, failing over until it succeeds, it
+// fails for a reason a replica cannot change, or every replica was tried.
+func (r *ReplicaSet) do(fn func(s *Smb) error) error {
+	if len(r.replicas) == 0 {
+		return ErrClosed
+	}
+	r.mutex.Lock()
+	i := r.pick()
+	r.mutex.Unlock()
+	var err error
+	for tried := 0; tried < len(r.replicas); tried++ {
+		if err = fn(r.replicas[i]); err == nil || !replicaFailure(err) {
+			return err
+		}
+		r.failover(i)
+		i = (i + 1) % len(r.replicas)
+	}
+	return err
+}
+
+// replicaFailure reports whether err may not happen on another replica.
+func replicaFailure(err error) bool {
+	return !errors.Is(err, io.EOF) &&
+		!errors.Is(err, os.ErrNotExist) &&
+		!errors.Is(err, os.ErrPermission) &&
+		!errors.Is(err, StatusNotADirectory) &&
+		!errors.Is(err, StatusFileIsADirectory)
+}
+
+// Stat stats path on the first replica that answers.
+func (r *ReplicaSet) Stat(path string) (info os.FileInfo, err error) {
+	err = r.do(func(s *Smb) (err error) {
+		info, err = s.Stat(path)
+		return
+	})
+	return
+}
+
+// ReadFile reads path from the first replica that serves it whole.
+func (r *ReplicaSet) ReadFile(path string) (data []byte, err error) {
+	err = r.do(func(s *Smb) (err error) {
+		data, err = s.ReadFile(path)
+		return
+	})
+	return
+}
+
+// Open opens path for reading. A read that fails midway reopens the file
+// on the next replica and goes on from the same offset. Files are read
+// raw, so with encryption set on a replica it fails with ErrNotSupported.
+func (r *ReplicaSet) Open(path string) (*ReplicaFile, error) {
+	f := &ReplicaFile{set: r, path: path}
+	err := r.do(f.open)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ReplicaFile is a file opened for reading through a ReplicaSet.
+type ReplicaFile struct {
+	set  *ReplicaSet
+	path string
+	file *smbFile
+	on   *Smb
+	pos  int64
+}
+
+// open opens the file on s at the current offset.
+func (f *ReplicaFile) open(s *Smb) error {
+	if f.file != nil && f.on == s {
+		return nil
+	}
+	file, err := s.OpenFile(f.path, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	if f.pos > 0 {
+		if _, err := file.Seek(f.pos, io.SeekStart); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, f.on = file, s
+	return nil
+}
+
+func (f *ReplicaFile) Read(p []byte) (n int, err error) {
+	if f.file == nil {
+		return 0, ErrClosed
+	}
+	err = f.set.do(func(s *Smb) error {
+		if err := f.open(s); err != nil {
+			return err
+		}
+		n, err = f.file.Read(p)
+		return err
+	})
+	f.pos += int64(n)
+	return
+}
+
+// Close closes the file on the replica it is open on.
+func (f *ReplicaFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
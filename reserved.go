@@ -0,0 +1,112 @@
+package libsmb2
+
+import (
+	"os"
+	"strings"
+)
+
+// ReservedNamePolicy selects what happens when a file is created with a
+// name Windows cannot store: a reserved device name (CON, NUL, COM1, ...)
+// or a name ending in a dot or space.
+type ReservedNamePolicy int
+
+const (
+	// ReservedNamesAllow sends names unchanged and leaves it to the server.
+	ReservedNamesAllow ReservedNamePolicy = iota
+	// ReservedNamesEscape rewrites offending names: an underscore is
+	// appended to a reserved base name ("CON.txt" becomes "CON_.txt") and
+	// a trailing dot or space is replaced by its SFM code point, which
+	// SetNameMapping with SFM maps back in listings.
+	//
+	// Only creates are rewritten. The underscore is not undone: ReadDir
+	// lists "CON_.txt", and later opens, Stat, Rename and Remove must use
+	// that name, since "CON_.txt" may as well be a file of that name.
+	ReservedNamesEscape
+	// ReservedNamesReject fails the create with a *ReservedNameError.
+	ReservedNamesReject
+)
+
+// ReservedNameError reports a name rejected under ReservedNamesReject.
+type ReservedNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ReservedNameError) Error() string {
+	return "invalid name " + e.Name + ": " + e.Reason
+}
+
+// SetReservedNamePolicy sets how names of created files are checked.
+func (s *Smb) SetReservedNamePolicy(p ReservedNamePolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reservedNames = p
+}
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// reservedReason explains why Windows cannot store name, or returns "".
+func reservedReason(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return "reserved device name"
+	}
+	switch name[len(name)-1] {
+	case '.':
+		return "trailing dot"
+	case ' ':
+		return "trailing space"
+	}
+	return ""
+}
+
+func escapeReserved(name string) string {
+	base, ext := name, ""
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		base, ext = name[:i], name[i:]
+	}
+	if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		name = base + "_" + ext
+	}
+	runes := []rune(name)
+	switch runes[len(runes)-1] {
+	case '.':
+		runes[len(runes)-1] = sfmTrailingDot
+	case ' ':
+		runes[len(runes)-1] = sfmTrailingSpace
+	}
+	return string(runes)
+}
+
+// createName applies the reserved name policy to the last component of a
+// path about to be created with flags. Must be called with s.mutex held.
+func (s *Smb) createName(op string, path string, flags int) (string, error) {
+	if s.reservedNames == ReservedNamesAllow || flags&os.O_CREATE == 0 {
+		return path, nil
+	}
+	dir, name := "", path
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+	reason := reservedReason(name)
+	if reason == "" {
+		return path, nil
+	}
+	if s.reservedNames == ReservedNamesReject {
+		return "", s.wrapError(op, path, &ReservedNameError{Name: name, Reason: reason})
+	}
+	return dir + escapeReserved(name), nil
+}
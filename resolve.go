@@ -0,0 +1,112 @@
+package libsmb2
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResolverOptions controls how Connect turns a server name into an
+// address before handing it to libsmb2, for networks whose DNS the system
+// resolver does not know.
+type ResolverOptions struct {
+	// Hosts maps names to addresses, like an /etc/hosts of the session's
+	// own. A mapped name is never looked up.
+	Hosts map[string]string `json:"hosts,omitempty"`
+	// Resolver looks up names not in Hosts, net.DefaultResolver if nil.
+	Resolver *net.Resolver `json:"-"`
+	// Prefer is "ip4" or "ip6" to try addresses of that family first, ""
+	// to keep the resolver's order.
+	Prefer string `json:"prefer,omitempty"`
+	// Probe, when set, makes Connect try the addresses of a name that
+	// resolves to several with a TCP connection of at most this long each
+	// and use the first that answers, instead of failing on one that is
+	// down. ConnectAttempts reports what was tried. Affinity, which picks
+	// its own node, does not probe.
+	Probe time.Duration `json:"probe,omitempty"`
+	// Parallel probes all addresses at once and takes the first to
+	// answer rather than trying them in order.
+	Parallel bool `json:"parallel,omitempty"`
+}
+
+// SetResolver makes Connect resolve server names itself with opts instead
+// of leaving it to libsmb2; nil restores the default. Affinity uses the
+// same lookup. As with affinity, the server is then reached by address,
+// which Kerberos cannot authenticate to.
+func (s *Smb) SetResolver(opts *ResolverOptions) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resolver = opts
+}
+
+// lookup returns the addresses of host in preference order. Must be
+// called with s.mutex held.
+func (s *Smb) lookup(host string) ([]string, error) {
+	opts := s.resolver
+	if opts == nil {
+		opts = &ResolverOptions{}
+	}
+	if addr, ok := opts.Hosts[host]; ok {
+		return []string{addr}, nil
+	}
+	r := opts.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs, err := r.LookupHost(context.Background(), host)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Prefer == "ip4" || opts.Prefer == "ip6" {
+		sort.SliceStable(addrs, func(i, j int) bool {
+			return isIPv6(addrs[i]) == (opts.Prefer == "ip6") && isIPv6(addrs[j]) != (opts.Prefer == "ip6")
+		})
+	}
+	return addrs, nil
+}
+
+func isIPv6(addr string) bool {
+	return strings.Contains(addr, ":")
+}
+
+// serverAddress picks what to pass libsmb2 for host, which may carry a
+// port. Must be called with s.mutex held.
+func (s *Smb) serverAddress(host string) (string, error) {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = host, ""
+	}
+	if (s.affinity == nil && s.resolver == nil) || net.ParseIP(name) != nil {
+		return host, nil
+	}
+	var addr string
+	if s.affinity != nil {
+		addr, err = s.affinity.resolve(name, s.lookup)
+	} else {
+		var addrs []string
+		if addrs, err = s.lookup(name); err == nil {
+			addr = addrs[0]
+			if s.resolver.Probe > 0 {
+				addr, err = s.probe(addrs, port, s.resolver)
+			}
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return joinServer(addr, port), nil
+}
+
+// joinServer formats addr and an optional port the way libsmb2 parses a
+// server, with IPv6 addresses in brackets.
+func joinServer(addr string, port string) string {
+	if port != "" {
+		return net.JoinHostPort(addr, port)
+	}
+	if isIPv6(addr) {
+		return "[" + addr + "]"
+	}
+	return addr
+}
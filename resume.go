@@ -0,0 +1,301 @@
+package libsmb2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// resumeSuffix names the sidecar kept next to a destination while a
+	// resumable copy is in progress.
+	resumeSuffix = ".smbresume"
+	// resumeChunk is the granularity at which progress is verified.
+	resumeChunk = 8 << 20
+)
+
+// resumeState is the sidecar content of a resumable copy.
+type resumeState struct {
+	Source    string    `json:"source"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mtime"`
+	ChunkSize int64     `json:"chunk_size"`
+	// Chunks holds the hex SHA-256 of every fully written chunk.
+	Chunks []string `json:"chunks"`
+}
+
+func (st *resumeState) matches(srcPath string, fi os.FileInfo) bool {
+	return st.Source == srcPath && st.Size == fi.Size() && st.ModTime.Equal(fi.ModTime()) && st.ChunkSize == resumeChunk
+}
+
+// loadResume reads the sidecar of dstPath and returns the state to continue
+// with and the offset the copy can resume from. A missing, unreadable or
+// stale sidecar starts the copy over.
+func loadResume(dst *Smb, dstPath string, srcPath string, fi os.FileInfo) (*resumeState, int64) {
+	fresh := &resumeState{Source: srcPath, Size: fi.Size(), ModTime: fi.ModTime(), ChunkSize: resumeChunk}
+	data, err := readResume(dst, dstPath)
+	if err != nil {
+		return fresh, 0
+	}
+	var st resumeState
+	if json.Unmarshal(data, &st) != nil || !st.matches(srcPath, fi) {
+		return fresh, 0
+	}
+	f, err := dst.open(dstPath, os.O_RDONLY)
+	if err != nil {
+		return fresh, 0
+	}
+	defer f.Close()
+	if offset := resumePoint(&st, f, nil); offset > 0 {
+		return &st, offset
+	}
+	return fresh, 0
+}
+
+// resumePoint returns the end of the last chunk of st that dst holds
+// intact and, if src is not nil, that src still has, trimming st.Chunks
+// to it; 0 means starting over.
+func resumePoint(st *resumeState, dst io.ReadSeeker, src io.ReadSeeker) int64 {
+	buf := make([]byte, resumeChunk)
+	intact := func(r io.ReadSeeker, i int) bool {
+		if _, err := r.Seek(int64(i)*resumeChunk, io.SeekStart); err != nil {
+			return false
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return false
+		}
+		sum := sha256.Sum256(buf)
+		return hex.EncodeToString(sum[:]) == st.Chunks[i]
+	}
+	for i := len(st.Chunks) - 1; i >= 0; i-- {
+		if intact(dst, i) && (src == nil || intact(src, i)) {
+			st.Chunks = st.Chunks[:i+1]
+			return int64(i+1) * resumeChunk
+		}
+	}
+	return 0
+}
+
+// readResume reads the sidecar raw, as saveResume writes it, whatever
+// content layers the session has.
+func readResume(dst *Smb, dstPath string) ([]byte, error) {
+	f, err := dst.open(dstPath+resumeSuffix, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// saveResume writes the sidecar raw, bypassing versioning and the
+// session's content layers.
+func saveResume(dst *Smb, dstPath string, st *resumeState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	f, err := dst.open(dstPath+resumeSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	return writeAndClose(f, bytes.NewReader(data))
+}
+
+// removeResume deletes the sidecar of a finished copy.
+func removeResume(dst *Smb, dstPath string) error {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	err := dst.remove(dstPath + resumeSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// chunkTracker hashes copied data per resume chunk and saves the sidecar
+// every time a chunk completes.
+type chunkTracker struct {
+	save  func(*resumeState) error
+	state *resumeState
+	h     hash.Hash
+	n     int64
+}
+
+func (t *chunkTracker) add(p []byte) error {
+	for len(p) > 0 {
+		take := int64(len(p))
+		if rest := resumeChunk - t.n; take > rest {
+			take = rest
+		}
+		t.h.Write(p[:take])
+		t.n += take
+		p = p[take:]
+		if t.n == resumeChunk {
+			t.state.Chunks = append(t.state.Chunks, hex.EncodeToString(t.h.Sum(nil)))
+			t.h.Reset()
+			t.n = 0
+			if err := t.save(t.state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// uploadSource names the source of a resumable upload in its sidecar.
+// The reader has no name, so its chunks are checked against the sidecar
+// as well before resuming.
+const uploadSource = "upload"
+
+// UploadResumable is Upload from a reader that can seek, keeping a
+// sidecar next to path so an interrupted upload resumes from the last
+// chunk both r and the share still hold instead of starting over. With
+// encryption or compression set, content cannot be resumed midway and
+// the upload always starts over.
+func (s *Smb) UploadResumable(path string, r io.ReadSeeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if s.layered() {
+		return s.Upload(path, r)
+	}
+	state := &resumeState{Source: uploadSource, Size: size, ChunkSize: resumeChunk}
+	var offset int64
+	if data, err := readResume(s, path); err == nil {
+		var st resumeState
+		if json.Unmarshal(data, &st) == nil && st.Source == uploadSource && st.Size == size && st.ChunkSize == resumeChunk {
+			if f, err := s.open(path, os.O_RDONLY); err == nil {
+				if offset = resumePoint(&st, f, r); offset > 0 {
+					state = &st
+				}
+				f.Close()
+			}
+		}
+	}
+	flags := os.O_WRONLY
+	if offset == 0 {
+		flags |= os.O_CREATE | os.O_TRUNC
+		s.mutex.Lock()
+		err = s.keepVersion(path)
+		s.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	out, err := s.open(path, flags)
+	if err != nil {
+		return err
+	}
+	save := func(st *resumeState) error { return saveResume(s, path, st) }
+	err = copyResumable(r, out, offset, &chunkTracker{save: save, state: state, h: sha256.New()})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return removeResume(s, path)
+}
+
+// DownloadResumable is Download to the local file localPath, keeping a
+// sidecar next to it so an interrupted download of the same, unchanged
+// file resumes from the last chunk intact on disk instead of starting
+// over. With encryption or compression set, content cannot be resumed
+// midway and the download always starts over.
+func (s *Smb) DownloadResumable(path string, localPath string) error {
+	fi, err := s.Stat(path)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(localPath, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	if s.layered() {
+		err = out.Truncate(0)
+		if err == nil {
+			err = s.Download(path, out)
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	state := &resumeState{Source: path, Size: fi.Size(), ModTime: fi.ModTime(), ChunkSize: resumeChunk}
+	var offset int64
+	if data, err := os.ReadFile(localPath + resumeSuffix); err == nil {
+		var st resumeState
+		if json.Unmarshal(data, &st) == nil && st.matches(path, fi) {
+			if offset = resumePoint(&st, out, nil); offset > 0 {
+				state = &st
+			}
+		}
+	}
+	if offset == 0 {
+		err = out.Truncate(0)
+	}
+	var in *smbFile
+	if err == nil {
+		in, err = s.open(path, os.O_RDONLY)
+	}
+	if err == nil {
+		save := func(st *resumeState) error {
+			data, err := json.Marshal(st)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(localPath+resumeSuffix, data, 0o666)
+		}
+		err = copyResumable(in, out, offset, &chunkTracker{save: save, state: state, h: sha256.New()})
+		in.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Remove(localPath + resumeSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// copyResumable copies in to out from offset on, feeding t.
+func copyResumable(in io.ReadSeeker, out io.WriteSeeker, offset int64, t *chunkTracker) error {
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, defaultCopyBuffer)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if werr := writeFull(out, buf[:n]); werr != nil {
+				return werr
+			}
+			if terr := t.add(buf[:n]); terr != nil {
+				return terr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
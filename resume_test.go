@@ -0,0 +1,71 @@
+package libsmb2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSession connects to the share named by LIBSMB2_TEST_SHARE, as
+// host/share, with LIBSMB2_TEST_USER and LIBSMB2_TEST_PASSWORD, and
+// skips the test when it is not set.
+func testSession(t *testing.T) *Smb {
+	t.Helper()
+	target := os.Getenv("LIBSMB2_TEST_SHARE")
+	host, share, ok := strings.Cut(target, "/")
+	if !ok {
+		t.Skip("LIBSMB2_TEST_SHARE not set")
+	}
+	s := NewSmb()
+	if err := s.Connect(host, share, os.Getenv("LIBSMB2_TEST_USER"), os.Getenv("LIBSMB2_TEST_PASSWORD")); err != nil {
+		t.Fatalf("Connect(%q) = %v", target, err)
+	}
+	t.Cleanup(s.Disconnect)
+	return s
+}
+
+func TestResumeSidecarLayered(t *testing.T) {
+	s := testSession(t)
+	s.SetEncryption(&KeyRing{Current: "k", Keys: map[string][]byte{"k": make([]byte, 32)}})
+	s.SetCompression(Gzip(gzip.DefaultCompression))
+
+	dst := "resume-test-" + randomName()
+	chunk := bytes.Repeat([]byte{0x5a}, resumeChunk)
+	f, err := s.open(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		t.Fatalf("open(%q) = %v", dst, err)
+	}
+	if err := writeAndClose(f, bytes.NewReader(chunk)); err != nil {
+		t.Fatalf("write %q: %v", dst, err)
+	}
+	defer removeCopied(s, dst)
+	defer removeCopied(s, dst+resumeSuffix)
+
+	src := fakeInfo{size: 2 * resumeChunk, mtime: time.Unix(1700000000, 0)}
+	sum := sha256.Sum256(chunk)
+	saved := &resumeState{Source: "src", Size: src.size, ModTime: src.mtime, ChunkSize: resumeChunk, Chunks: []string{hex.EncodeToString(sum[:])}}
+	if err := saveResume(s, dst, saved); err != nil {
+		t.Fatalf("saveResume() = %v", err)
+	}
+	st, offset := loadResume(s, dst, "src", src)
+	if offset != resumeChunk || len(st.Chunks) != 1 {
+		t.Fatalf("loadResume() = %d bytes, %d chunks; want %d, 1", offset, len(st.Chunks), resumeChunk)
+	}
+}
+
+type fakeInfo struct {
+	size  int64
+	mtime time.Time
+}
+
+func (fi fakeInfo) Name() string       { return "src" }
+func (fi fakeInfo) Size() int64        { return fi.size }
+func (fi fakeInfo) Mode() os.FileMode  { return 0 }
+func (fi fakeInfo) ModTime() time.Time { return fi.mtime }
+func (fi fakeInfo) IsDir() bool        { return false }
+func (fi fakeInfo) Sys() any           { return nil }
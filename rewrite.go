@@ -0,0 +1,27 @@
+package libsmb2
+
+// PathRewriter maps the path a caller passed for op to the path sent to
+// the server, for gateways that present a virtual namespace: adding a
+// tenant prefix, mapping one tree onto another, or flattening DFS links
+// to their targets. Returning an error fails the operation with it.
+//
+// It is called with the session locked, once for every path of every
+// server request, so it must not call back into the session.
+type PathRewriter func(op string, path string) (string, error)
+
+// SetPathRewriter installs r for all later operations, nil removes it.
+// Errors keep reporting the caller's path rather than the rewritten one.
+func (s *Smb) SetPathRewriter(r PathRewriter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rewrite = r
+}
+
+// rewritePath applies the rewriter, if any. Must be called with s.mutex
+// held.
+func (s *Smb) rewritePath(op string, path string) (string, error) {
+	if s.rewrite == nil {
+		return path, nil
+	}
+	return s.rewrite(op, path)
+}
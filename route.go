@@ -0,0 +1,125 @@
+package libsmb2
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Route splits the traffic for one share: reads go to a ReplicaSet of
+// read-only copies, everything that changes the share goes to Primary.
+// Keep one Route per share, so reporting shares can be offloaded to
+// replicas while others read from their primary.
+type Route struct {
+	Primary  *Smb
+	Replicas *ReplicaSet
+	// Settle, when set, reads paths written through the route from the
+	// primary for that long afterwards, as replicas lag behind writes.
+	Settle time.Duration
+
+	mutex   sync.Mutex
+	written map[string]time.Time
+}
+
+// reader returns where a read of path goes: nil for the replicas, the
+// primary for a path written within Settle.
+func (r *Route) reader(path string) *Smb {
+	if r.Replicas == nil {
+		return r.Primary
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if at, ok := r.written[path]; ok {
+		if time.Since(at) < r.Settle {
+			return r.Primary
+		}
+		delete(r.written, path)
+	}
+	return nil
+}
+
+// wrote notes a change to path for Settle.
+func (r *Route) wrote(path string) {
+	if r.Settle <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.written == nil {
+		r.written = make(map[string]time.Time)
+	}
+	r.written[path] = time.Now()
+}
+
+// Stat stats path on a replica.
+func (r *Route) Stat(path string) (os.FileInfo, error) {
+	if s := r.reader(path); s != nil {
+		return s.Stat(path)
+	}
+	return r.Replicas.Stat(path)
+}
+
+// ReadFile reads path from a replica.
+func (r *Route) ReadFile(path string) ([]byte, error) {
+	if s := r.reader(path); s != nil {
+		return s.ReadFile(path)
+	}
+	return r.Replicas.ReadFile(path)
+}
+
+// Open opens path for reading on a replica.
+func (r *Route) Open(path string) (io.ReadCloser, error) {
+	if s := r.reader(path); s != nil {
+		f, err := s.OpenFile(path, os.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	f, err := r.Replicas.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFile opens path on the primary, for writing or for reads that must
+// see the latest state.
+func (r *Route) OpenFile(path string, flags int) (*smbFile, error) {
+	if flags&(os.O_WRONLY|os.O_RDWR) != 0 {
+		r.wrote(path)
+	}
+	return r.Primary.OpenFile(path, flags)
+}
+
+// WriteFile writes data to path on the primary.
+func (r *Route) WriteFile(path string, data []byte) error {
+	r.wrote(path)
+	return r.Primary.WriteFile(path, data)
+}
+
+// Upload writes the content of src to path on the primary.
+func (r *Route) Upload(path string, src io.Reader) error {
+	r.wrote(path)
+	return r.Primary.Upload(path, src)
+}
+
+// Mkdir creates the directory path on the primary.
+func (r *Route) Mkdir(path string) error {
+	r.wrote(path)
+	return r.Primary.Mkdir(path)
+}
+
+// Remove removes path on the primary.
+func (r *Route) Remove(path string) error {
+	r.wrote(path)
+	return r.Primary.Remove(path)
+}
+
+// Rename renames oldpath to newpath on the primary.
+func (r *Route) Rename(oldpath string, newpath string) error {
+	r.wrote(oldpath)
+	r.wrote(newpath)
+	return r.Primary.Rename(oldpath, newpath)
+}
@@ -0,0 +1,121 @@
+package libsmb2
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// scanDepth is the number of blocks a Scanner reads ahead.
+const scanDepth = 2
+
+// Scanner is a bufio.Scanner over a remote file that reads it in blocks
+// of the negotiated maximum read size, keeping the next blocks in flight
+// while the current one is scanned, so line-by-line processing costs one
+// round trip per block rather than per line. It splits lines by default;
+// tokens may be up to four blocks long. An open Scanner holds up to seven
+// blocks of memory.
+type Scanner struct {
+	*bufio.Scanner
+	ahead *readAhead
+}
+
+// NewScanner opens path for scanning.
+func (s *Smb) NewScanner(path string) (*Scanner, error) {
+	f, err := s.OpenFile(path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	block := int(s.Limits().MaxReadSize)
+	if block <= 0 {
+		block = defaultCopyBuffer
+	}
+	ahead := newReadAhead(f, block)
+	sc := bufio.NewScanner(ahead)
+	sc.Buffer(make([]byte, 0, block), 4*block)
+	return &Scanner{Scanner: sc, ahead: ahead}, nil
+}
+
+// Close stops reading ahead and closes the file.
+func (s *Scanner) Close() error {
+	return s.ahead.Close()
+}
+
+// readAhead reads r in blocks on a goroutine, scanDepth blocks ahead of
+// its Read calls.
+type readAhead struct {
+	r    io.ReadCloser
+	full chan block
+	free chan []byte
+	done chan struct{}
+	cur  []byte
+	rest []byte
+	err  error
+}
+
+type block struct {
+	buf []byte
+	err error
+}
+
+func newReadAhead(r io.ReadCloser, size int) *readAhead {
+	ra := &readAhead{
+		r:    r,
+		full: make(chan block, scanDepth),
+		free: make(chan []byte, scanDepth+1),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < scanDepth+1; i++ {
+		ra.free <- make([]byte, size)
+	}
+	go ra.fill()
+	return ra
+}
+
+func (ra *readAhead) fill() {
+	defer close(ra.full)
+	for {
+		var buf []byte
+		select {
+		case buf = <-ra.free:
+		case <-ra.done:
+			return
+		}
+		n, err := ra.r.Read(buf)
+		select {
+		case ra.full <- block{buf: buf[:n], err: err}:
+		case <-ra.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (ra *readAhead) Read(p []byte) (int, error) {
+	for len(ra.rest) == 0 {
+		if ra.err != nil {
+			return 0, ra.err
+		}
+		if ra.cur != nil {
+			ra.free <- ra.cur[:cap(ra.cur)]
+			ra.cur = nil
+		}
+		b, ok := <-ra.full
+		if !ok {
+			return 0, io.EOF
+		}
+		ra.cur, ra.rest, ra.err = b.buf, b.buf, b.err
+	}
+	n := copy(p, ra.rest)
+	ra.rest = ra.rest[n:]
+	return n, nil
+}
+
+func (ra *readAhead) Close() error {
+	close(ra.done)
+	for range ra.full {
+	}
+	return ra.r.Close()
+}
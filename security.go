@@ -0,0 +1,42 @@
+package libsmb2
+
+//#include "libsmb2go.h"
+import "C"
+
+// SetSeal requests SMB3 encryption for the session. Must be called before
+// Connect.
+func (s *Smb) SetSeal(on bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session == nil {
+		return s.wrapError("set seal", "", ErrClosed)
+	}
+	val := 0
+	if on {
+		val = 1
+	}
+	if C.libsmb2go_set_seal(s.session, C.int(val)) == -C.ENOTSUP {
+		return s.wrapError("set seal", "", ErrNotSupported)
+	}
+	s.seal = on
+	return nil
+}
+
+// SetKerberos selects Kerberos instead of NTLMSSP authentication. Must be
+// called before Connect.
+func (s *Smb) SetKerberos(on bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session == nil {
+		return s.wrapError("set authentication", "", ErrClosed)
+	}
+	val := 0
+	if on {
+		val = 1
+	}
+	if C.libsmb2go_set_authentication(s.session, C.int(val)) == -C.ENOTSUP {
+		return s.wrapError("set authentication", "", ErrNotSupported)
+	}
+	s.kerberos = on
+	return nil
+}
@@ -0,0 +1,60 @@
+package libsmb2
+
+import (
+	"context"
+	"errors"
+)
+
+// track registers an open file so Shutdown can close it. Must be called
+// with s.mutex held.
+func (s *Smb) track(f *smbFile) {
+	if s.files == nil {
+		s.files = make(map[*smbFile]struct{})
+	}
+	s.files[f] = struct{}{}
+}
+
+// Shutdown drains the session for a clean restart. New operations fail
+// with ErrClosed at once, closing files excepted. Operations already
+// running are waited for until ctx is done. Then the writers of
+// compressed or encrypted content still open are closed, writing out the
+// chunks they hold, every file still open is closed and the session
+// disconnected. A writer still busy with a Write after ctx ended is cut
+// short. The returned error is ctx's if it ended the wait, joined with
+// those of closing the writers; the session is disconnected all the
+// same.
+func (s *Smb) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.draining = true
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	idle := s.idle
+	if s.inflight == 0 {
+		close(idle)
+		s.idle = nil
+	}
+	s.mutex.Unlock()
+
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if werr := s.closeWriters(); werr != nil {
+		err = errors.Join(err, werr)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session != nil {
+		for f := range s.files {
+			f.release()
+		}
+	}
+	s.files = nil
+	s.writers = nil
+	s.disconnect()
+	return err
+}
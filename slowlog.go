@@ -0,0 +1,38 @@
+package libsmb2
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// SetSlowOpThreshold makes the session log every operation that takes
+// longer than d, together with its path and transferred size. A nil logger
+// uses the standard logger, a zero duration disables the logging.
+func (s *Smb) SetSlowOpThreshold(d time.Duration, logger *log.Logger) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.slowThreshold = d
+	s.slowLogger = logger
+}
+
+// logSlow logs op if it started more than the slow threshold ago.
+// Must be called with s.mutex held.
+func (s *Smb) logSlow(op string, path string, size int64, start time.Time) {
+	if s.slowThreshold <= 0 {
+		return
+	}
+	d := time.Since(start)
+	if d < s.slowThreshold {
+		return
+	}
+	line := fmt.Sprintf("libsmb2: slow %s path=%q size=%d duration=%s", op, path, size, d)
+	if len(s.tags) > 0 {
+		line += " " + formatTags(s.tags)
+	}
+	if s.slowLogger != nil {
+		s.slowLogger.Print(line)
+	} else {
+		log.Print(line)
+	}
+}
@@ -0,0 +1,145 @@
+package libsmb2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	path2 "path"
+)
+
+// defaultSpaceCheck is how much a SpaceWriter writes between checks.
+const defaultSpaceCheck = 64 << 20
+
+// ErrInsufficientSpace is matched by the *SpaceError of a SpaceWriter
+// that found the share too full to go on.
+var ErrInsufficientSpace = errors.New("insufficient space")
+
+// SpaceError reports a write stopped for lack of space: Needed bytes
+// were about to be written where the connected user, quota included, had
+// only Available.
+type SpaceError struct {
+	Path      string
+	Needed    uint64
+	Available uint64
+}
+
+func (e *SpaceError) Error() string {
+	return fmt.Sprintf("%s: %v: need %d bytes, %d available", e.Path, ErrInsufficientSpace, e.Needed, e.Available)
+}
+
+func (e *SpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// SpaceOptions tunes CreateChecked.
+type SpaceOptions struct {
+	// Size is the full size to be written, if known; it is checked up
+	// front, and the rest of it at every later check.
+	Size int64
+	// Reserve is head room to leave free on the share.
+	Reserve uint64
+	// CheckEvery is the number of bytes written between checks, default
+	// 64 MiB. Without a Size, each check asks for this much to be free.
+	CheckEvery int64
+}
+
+// CreateChecked is Create with the free space of the share checked before
+// the first byte and again every CheckEvery bytes, so that a write that
+// would run out fails early with a *SpaceError, and the partial file is
+// removed, instead of meeting STATUS_DISK_FULL midway through. The
+// checks rest on Statvfs: on builds or servers without it, the writer
+// does no checking. Other writers filling the share between two checks
+// can still make a write fail with the server's error.
+func (s *Smb) CreateChecked(path string, opts *SpaceOptions) (io.WriteCloser, error) {
+	w := &spaceWriter{smb: s, path: path, every: defaultSpaceCheck}
+	if opts != nil {
+		w.size, w.reserve = opts.Size, opts.Reserve
+		if opts.CheckEvery > 0 {
+			w.every = opts.CheckEvery
+		}
+	}
+	if err := w.check(0); err != nil {
+		return nil, err
+	}
+	f, err := s.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w.w = f
+	return w, nil
+}
+
+type spaceWriter struct {
+	smb     *Smb
+	path    string
+	w       io.WriteCloser
+	size    int64
+	reserve uint64
+	every   int64
+	written int64
+	// due is the count written at which the next check is made.
+	due int64
+	err error
+}
+
+// check verifies there is room for what is still to come, at least next
+// bytes. It asks about the parent directory, since the file itself may
+// not exist yet; "" is the share root.
+func (w *spaceWriter) check(next int) error {
+	dir := path2.Dir(w.path)
+	if dir == "." || dir == "/" {
+		dir = ""
+	}
+	st, err := w.smb.Statvfs(dir)
+	if errors.Is(err, ErrNotSupported) {
+		w.due = -1
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	need := uint64(w.every)
+	if uint64(next) > need {
+		need = uint64(next)
+	}
+	if w.size > 0 {
+		need = 0
+		if w.size > w.written {
+			need = uint64(w.size - w.written)
+		}
+	}
+	if avail := st.Available(); avail < need+w.reserve {
+		return &SpaceError{Path: w.path, Needed: need, Available: avail}
+	}
+	w.due = w.written + w.every
+	return nil
+}
+
+func (w *spaceWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.due >= 0 && w.written+int64(len(p)) > w.due {
+		if err := w.check(len(p)); err != nil {
+			w.abort(err)
+			return 0, err
+		}
+	}
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// abort discards the partial file.
+func (w *spaceWriter) abort(err error) {
+	w.err = err
+	w.w.Close()
+	removeCopied(w.smb, w.path)
+}
+
+func (w *spaceWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Close()
+}
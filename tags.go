@@ -0,0 +1,107 @@
+package libsmb2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SetTags labels the session, for instance with a tenant or job ID. The
+// tags are attached to every Call seen by middleware and to slow-op log
+// lines. The map is copied; nil clears the tags.
+func (s *Smb) SetTags(tags map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tags = nil
+	if len(tags) > 0 {
+		s.tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			s.tags[k] = v
+		}
+	}
+}
+
+// Tags returns a copy of the session's tags.
+func (s *Smb) Tags() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// formatTags renders tags as sorted key=value pairs for log lines.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// Usage is what an Accountant has counted for one tag value.
+type Usage struct {
+	Ops          int64
+	Errors       int64
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Accountant is middleware that totals usage per value of one tag, so a
+// gateway shared by several tenants can attribute load to each. Install
+// it with Use on every session to be counted; sessions without the tag
+// are counted under "".
+type Accountant struct {
+	key   string
+	mutex sync.Mutex
+	usage map[string]*Usage
+}
+
+// NewAccountant returns an Accountant grouping by the tag named key.
+func NewAccountant(key string) *Accountant {
+	return &Accountant{key: key, usage: make(map[string]*Usage)}
+}
+
+// Middleware returns the middleware that feeds the Accountant.
+func (a *Accountant) Middleware() Middleware {
+	return func(next Invoker) Invoker {
+		return func(c *Call) error {
+			err := next(c)
+			a.mutex.Lock()
+			defer a.mutex.Unlock()
+			u := a.usage[c.Tags[a.key]]
+			if u == nil {
+				u = &Usage{}
+				a.usage[c.Tags[a.key]] = u
+			}
+			u.Ops++
+			if err != nil && !errors.Is(err, io.EOF) {
+				u.Errors++
+			}
+			switch c.Op {
+			case "read":
+				u.BytesRead += int64(c.Bytes)
+			case "write":
+				u.BytesWritten += int64(c.Bytes)
+			}
+			return err
+		}
+	}
+}
+
+// Usage returns a snapshot of the totals by tag value.
+func (a *Accountant) Usage() map[string]Usage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	res := make(map[string]Usage, len(a.usage))
+	for k, u := range a.usage {
+		res[k] = *u
+	}
+	return res
+}
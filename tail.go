@@ -0,0 +1,96 @@
+package libsmb2
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// TailOptions tunes TailFile.
+type TailOptions struct {
+	// Poll is how often to look for new data once the end is reached,
+	// default one second. libsmb2 has no change notification to wait on.
+	Poll time.Duration
+	// FromStart reads the existing content first instead of only what is
+	// appended from now on.
+	FromStart bool
+}
+
+// TailFile follows path as it grows, like tail -f. Reads block until new
+// bytes arrive or ctx is done, in which case they fail with ctx's error.
+// When the file shrinks or is replaced, as on log rotation, following
+// starts over at the beginning of the new file.
+func (s *Smb) TailFile(ctx context.Context, path string, opts *TailOptions) (io.ReadCloser, error) {
+	t := &tailReader{smb: s, path: path, ctx: ctx, poll: time.Second}
+	if opts != nil && opts.Poll > 0 {
+		t.poll = opts.Poll
+	}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	if opts == nil || !opts.FromStart {
+		if _, err := t.file.Seek(0, io.SeekEnd); err != nil {
+			t.file.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+type tailReader struct {
+	smb  *Smb
+	path string
+	ctx  context.Context
+	poll time.Duration
+	file *smbFile
+	ino  uint64
+}
+
+func (t *tailReader) open() error {
+	f, err := t.smb.OpenFile(t.path, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.file, t.ino = f, fileIno(f)
+	return nil
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.file.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(t.poll):
+		}
+		info, err := t.smb.Stat(t.path)
+		if err != nil {
+			// Between rotation steps the file may be briefly missing.
+			continue
+		}
+		if info.Size() < t.file.pos || fileIno(info) != t.ino {
+			if err := t.open(); err != nil {
+				continue
+			}
+		}
+	}
+}
+
+func (t *tailReader) Close() error {
+	return t.file.Close()
+}
+
+// fileIno returns the file index of info, 0 if the server gave none.
+func fileIno(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*FileStat); ok && st != nil {
+		return st.Ino
+	}
+	return 0
+}
@@ -0,0 +1,22 @@
+#!/bin/sh
+# Builds the pinned libsmb2 as a static archive for -tags libsmb2_vendored.
+# Keep LIBSMB2_VERSION in sync with vendoredVersion in link_vendored.go.
+set -e
+
+LIBSMB2_VERSION=v6.1.0
+LIBSMB2_REPO=https://github.com/sahlberg/libsmb2.git
+
+cd "$(dirname "$0")"
+prefix="$(pwd)/libsmb2"
+src="$(pwd)/libsmb2-src"
+
+rm -rf "$src" "$prefix"
+git clone --depth 1 --branch "$LIBSMB2_VERSION" "$LIBSMB2_REPO" "$src"
+cmake -S "$src" -B "$src/build" \
+	-DBUILD_SHARED_LIBS=OFF \
+	-DCMAKE_POSITION_INDEPENDENT_CODE=ON \
+	-DCMAKE_INSTALL_PREFIX="$prefix" \
+	-DCMAKE_INSTALL_LIBDIR=lib
+cmake --build "$src/build"
+cmake --install "$src/build"
+rm -rf "$src"
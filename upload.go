@@ -0,0 +1,138 @@
+package libsmb2
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	path2 "path"
+	"strconv"
+	"strings"
+)
+
+// uploadChunk is the buffer size Upload reads its source with.
+const uploadChunk = 1 << 20
+
+// Versioning keeps the previous content of files replaced by Upload and
+// WriteFile. The old file is renamed to name.~N~, N one past the highest
+// existing version, before the new content is written.
+type Versioning struct {
+	// Dir, when set, collects the versions in a subfolder of the file's
+	// directory (for example ".versions") instead of next to the file.
+	Dir string
+}
+
+// SetVersioning enables versioning on overwrite, nil disables it.
+func (s *Smb) SetVersioning(v *Versioning) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.versioning = v
+}
+
+// WriteFile replaces the content of path with data, creating it if needed.
+func (s *Smb) WriteFile(path string, data []byte) error {
+	return s.Upload(path, bytes.NewReader(data))
+}
+
+// Upload replaces the content of path with everything read from r,
+// creating it if needed. UploadResumable can pick up an interrupted
+// upload where it stopped.
+func (s *Smb) Upload(path string, r io.Reader) error {
+	w, err := s.Create(path)
+	if err != nil {
+		return err
+	}
+	return writeAndClose(w, r)
+}
+
+// Create opens path for writing its new content, creating it if needed
+// and keeping a version of the old one if versioning is on. The content
+// passes through the session's compression and encryption, if set.
+func (s *Smb) Create(path string) (io.WriteCloser, error) {
+	s.mutex.Lock()
+	err := s.keepVersion(path)
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.open(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+	return s.contentWriter(f)
+}
+
+// writeAndClose copies r into f and closes it.
+func writeAndClose(f io.WriteCloser, r io.Reader) error {
+	buf := make([]byte, uploadChunk)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if err := writeFull(f, buf[:n]); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		} else if rerr != nil {
+			f.Close()
+			return rerr
+		}
+	}
+	return f.Close()
+}
+
+// writeFull writes all of p, continuing after short writes.
+func writeFull(w io.Writer, p []byte) error {
+	for len(p) > 0 {
+		n, err := w.Write(p)
+		if err != nil {
+			return err
+		}
+		p = p[n:]
+	}
+	return nil
+}
+
+// keepVersion renames an existing path out of the way when versioning is
+// enabled. Must be called with s.mutex held.
+func (s *Smb) keepVersion(path string) error {
+	if s.versioning == nil {
+		return nil
+	}
+	if _, err := s.stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	dir, name := path2.Split(strings.Trim(path, "/"))
+	versionDir := strings.TrimSuffix(dir, "/")
+	if s.versioning.Dir != "" {
+		versionDir = path2.Join(versionDir, s.versioning.Dir)
+		if err := s.mkdirAll(versionDir); err != nil {
+			return err
+		}
+	}
+	names, err := s.readDirNames(versionDir)
+	if err != nil {
+		return err
+	}
+	next := 1
+	for _, existing := range names {
+		if n, ok := versionNumber(existing, name); ok && n >= next {
+			next = n + 1
+		}
+	}
+	return s.rename(path, path2.Join(versionDir, name+".~"+strconv.Itoa(next)+"~"))
+}
+
+// versionNumber parses the N out of a name.~N~ version of name.
+func versionNumber(candidate string, name string) (int, bool) {
+	prefix := name + ".~"
+	if !strings.HasPrefix(candidate, prefix) || !strings.HasSuffix(candidate, "~") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(candidate[len(prefix) : len(candidate)-1])
+	return n, err == nil && n > 0
+}
@@ -0,0 +1,41 @@
+package libsmb2
+
+import (
+	"context"
+	"time"
+)
+
+// ValidateCredentials checks that user and password can log on to host,
+// then logs off, so a login form can be answered before any share is
+// chosen. It uses a session of its own with the settings of s (seal,
+// Kerberos, strict, timeout, resolver) and leaves s untouched. libsmb2
+// cannot set up a session without connecting a tree, so it connects to
+// IPC$, which every server offers to any authenticated user. libsmb2
+// calls cannot be interrupted, so when ctx ends first ctx's error is
+// returned while the logon finishes and is torn down in the background;
+// a deadline on ctx also serves as the session's timeout if s has none.
+func (s *Smb) ValidateCredentials(ctx context.Context, host string, user string, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d := s.Describe()
+	d.Host, d.Share = host, "IPC$"
+	d.IdleTimeout = 0
+	if deadline, ok := ctx.Deadline(); ok && d.Timeout == 0 {
+		d.Timeout = time.Until(deadline)
+	}
+	done := make(chan error, 1)
+	go func() {
+		v, err := d.Open(user, password)
+		if err == nil {
+			v.Disconnect()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
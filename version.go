@@ -0,0 +1,19 @@
+package libsmb2
+
+// LibraryInfo describes the libsmb2 the bindings were built against.
+type LibraryInfo struct {
+	// Version is the libsmb2 release, "unknown" for a system library built
+	// without -DLIBSMB2GO_LIBSMB2_VERSION in CGO_CFLAGS.
+	Version string
+	// Linkage is "system" (shared), "static" (system archive) or
+	// "vendored" (archive built by third_party/build-libsmb2.sh).
+	Linkage string
+}
+
+// Version reports which libsmb2 the bindings are linked against.
+func Version() LibraryInfo {
+	return LibraryInfo{
+		Version: linkedVersion(),
+		Linkage: linkage,
+	}
+}
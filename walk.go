@@ -0,0 +1,213 @@
+package libsmb2
+
+import (
+	"os"
+	path2 "path"
+	"path/filepath"
+	"sync"
+)
+
+// WalkFunc is called by Walk for every visited entry, with the same
+// contract as filepath.WalkFunc: returning filepath.SkipDir skips the
+// directory (or the rest of the parent, for a file), any other error stops
+// the walk.
+type WalkFunc func(path string, info os.FileInfo, err error) error
+
+// Walk walks the tree rooted at root, calling fn for every entry in
+// lexical order. Each directory is listed in full and sorted by the bytes
+// of its names before it is visited, so the order is the same from run to
+// run whatever order the server returns entries in. Directory listings are
+// read one at a time, so memory stays bounded by the widest directory
+// rather than the tree.
+func (s *Smb) Walk(root string, fn WalkFunc) error {
+	info, err := s.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = s.walk(root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (s *Smb) walk(path string, info os.FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	s.mutex.Lock()
+	entries, err := s.listDir(path)
+	s.mutex.Unlock()
+	if err1 := fn(path, info, err); err != nil || err1 != nil {
+		return err1
+	}
+	for _, entry := range entries {
+		if err := s.walk(path2.Join(path, entry.Name()), entry, fn); err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WalkOptions tunes WalkPrefetch.
+type WalkOptions struct {
+	// Prefetch is how many sibling directories ahead of the visitor are
+	// listed in the background, default 4.
+	Prefetch int
+	// Sessions are extra sessions connected to the same share to list
+	// on. Without them listings still overlap with the visitor's own work,
+	// but not with each other, since one session serializes its calls.
+	Sessions []*Smb
+}
+
+// WalkPrefetch is Walk with directory listings fetched ahead of fn, so
+// deep trees are not walked one round trip at a time. The visiting order
+// and the meaning of fn's results are the same as Walk's. Each session
+// lists on its own worker; listings are handed out round-robin, and a
+// worker whose queue runs dry steals the oldest pending listing of the
+// busiest other, so one slow session does not hold up the rest. Memory
+// stays bounded by the prefetch depth along the path being visited, not
+// by the size of the tree.
+func (s *Smb) WalkPrefetch(root string, opts *WalkOptions, fn WalkFunc) error {
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+	depth := opts.Prefetch
+	if depth <= 0 {
+		depth = 4
+	}
+	w := &prefetcher{
+		sessions: append([]*Smb{s}, opts.Sessions...),
+		depth:    depth,
+	}
+	w.start()
+	defer w.stop()
+	info, err := s.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, info, w.fetch(root, info), fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+type prefetcher struct {
+	sessions []*Smb
+	depth    int
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	// queues holds the pending listings of each session's worker, oldest
+	// first.
+	queues  [][]*listing
+	next    int
+	stopped bool
+}
+
+// listing is a directory listing that may still be in flight.
+type listing struct {
+	path    string
+	done    chan struct{}
+	entries []os.FileInfo
+	err     error
+}
+
+func (w *prefetcher) start() {
+	w.cond = sync.NewCond(&w.mutex)
+	w.queues = make([][]*listing, len(w.sessions))
+	for i := range w.sessions {
+		go w.work(i)
+	}
+}
+
+// stop ends the workers. Listings still queued are dropped, since nothing
+// waits for them once the walk has returned.
+func (w *prefetcher) stop() {
+	w.mutex.Lock()
+	w.stopped = true
+	w.mutex.Unlock()
+	w.cond.Broadcast()
+}
+
+// fetch queues path for listing, or returns nil for a file.
+func (w *prefetcher) fetch(path string, info os.FileInfo) *listing {
+	if !info.IsDir() {
+		return nil
+	}
+	l := &listing{path: path, done: make(chan struct{})}
+	w.mutex.Lock()
+	i := w.next % len(w.queues)
+	w.next++
+	w.queues[i] = append(w.queues[i], l)
+	w.mutex.Unlock()
+	w.cond.Broadcast()
+	return l
+}
+
+// take returns the next listing for worker i: the oldest of its own
+// queue, else the oldest of the longest other queue. It returns nil once
+// the walk is over. Must be called with w.mutex held.
+func (w *prefetcher) take(i int) *listing {
+	for !w.stopped {
+		victim := i
+		if len(w.queues[i]) == 0 {
+			for j, q := range w.queues {
+				if len(q) > len(w.queues[victim]) {
+					victim = j
+				}
+			}
+		}
+		if q := w.queues[victim]; len(q) > 0 {
+			w.queues[victim] = q[1:]
+			return q[0]
+		}
+		w.cond.Wait()
+	}
+	return nil
+}
+
+func (w *prefetcher) work(i int) {
+	s := w.sessions[i]
+	for {
+		w.mutex.Lock()
+		l := w.take(i)
+		w.mutex.Unlock()
+		if l == nil {
+			return
+		}
+		s.mutex.Lock()
+		l.entries, l.err = s.listDir(l.path)
+		s.mutex.Unlock()
+		close(l.done)
+	}
+}
+
+func (w *prefetcher) walk(path string, info os.FileInfo, l *listing, fn WalkFunc) error {
+	if l == nil {
+		return fn(path, info, nil)
+	}
+	<-l.done
+	if err1 := fn(path, info, l.err); l.err != nil || err1 != nil {
+		return err1
+	}
+	ahead := make([]*listing, len(l.entries))
+	started := 0
+	for i, entry := range l.entries {
+		for ; started < len(l.entries) && started <= i+w.depth; started++ {
+			e := l.entries[started]
+			ahead[started] = w.fetch(path2.Join(path, e.Name()), e)
+		}
+		if err := w.walk(path2.Join(path, entry.Name()), entry, ahead[i], fn); err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+		ahead[i] = nil
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build libsmb2_faults
+// +build libsmb2_faults
+
+package libsmb2
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WANProfile describes simulated network conditions. libsmb2 owns its
+// socket, so the conditions are applied per operation rather than per
+// packet: every call pays one round trip plus the time to move its payload
+// through the bandwidth cap. The session stays locked during the delay, as
+// it does while a real request is outstanding.
+type WANProfile struct {
+	// RTT is the round trip time added to every operation.
+	RTT time.Duration
+	// Jitter is the maximum random delay added on top of RTT.
+	Jitter time.Duration
+	// Bandwidth caps the payload rate in bytes per second, 0 is unlimited.
+	Bandwidth int64
+	// Loss is the probability, between 0 and 1, that an operation suffers
+	// a lost packet and pays RetransmitTimeout on top of its round trip.
+	Loss float64
+	// RetransmitTimeout defaults to three round trips.
+	RetransmitTimeout time.Duration
+}
+
+// SetWANProfile makes the session simulate the network conditions in p.
+// A nil p disables the simulation. Only available when built with the
+// libsmb2_faults tag.
+func (s *Smb) SetWANProfile(p *WANProfile) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults.wan = p
+}
+
+// delay returns how long an operation moving size bytes takes on p.
+func (p *WANProfile) delay(size int) time.Duration {
+	d := p.RTT
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	if p.Bandwidth > 0 && size > 0 {
+		d += time.Duration(int64(size) * int64(time.Second) / p.Bandwidth)
+	}
+	if p.Loss > 0 && rand.Float64() < p.Loss {
+		if p.RetransmitTimeout > 0 {
+			d += p.RetransmitTimeout
+		} else {
+			d += 3 * p.RTT
+		}
+	}
+	return d
+}
@@ -0,0 +1,162 @@
+package libsmb2
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ChangeKind says what a ChangeEvent reports.
+type ChangeKind int
+
+const (
+	EntryAdded ChangeKind = iota
+	EntryChanged
+	EntryRemoved
+	// TreeReady follows the entries of the initial scan.
+	TreeReady
+	// ScanFailed reports a scan that could not complete; the view is
+	// kept as it was and the next poll tries again.
+	ScanFailed
+)
+
+var changeKindNames = [...]string{
+	EntryAdded:   "added",
+	EntryChanged: "changed",
+	EntryRemoved: "removed",
+	TreeReady:    "ready",
+	ScanFailed:   "failed",
+}
+
+func (k ChangeKind) String() string {
+	if int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return "unknown"
+}
+
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// ChangeEvent is sent by WatchTree. Entry is the entry as it now is, or as
+// it last was for EntryRemoved; Err is set on ScanFailed only.
+type ChangeEvent struct {
+	Kind  ChangeKind
+	Entry ManifestEntry
+	Err   error
+}
+
+// MarshalJSON encodes the event with its error as a string.
+func (e ChangeEvent) MarshalJSON() ([]byte, error) {
+	var msg string
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Kind  ChangeKind     `json:"kind"`
+		Entry *ManifestEntry `json:"entry,omitempty"`
+		Err   string         `json:"error,omitempty"`
+	}{e.Kind, entryOrNil(e), msg})
+}
+
+func entryOrNil(e ChangeEvent) *ManifestEntry {
+	if e.Kind == TreeReady || e.Kind == ScanFailed {
+		return nil
+	}
+	return &e.Entry
+}
+
+// WatchOptions tunes WatchTree.
+type WatchOptions struct {
+	// Poll is the time between scans, default 30 seconds.
+	Poll time.Duration
+	// Hash, a Manifest hash name, makes content changes that keep size
+	// and times visible too, at the cost of reading changed files.
+	Hash string
+	// Index, with Hash, keeps digests across scans and runs, so only
+	// files that changed are read.
+	Index *Index
+}
+
+// WatchTree maintains a view of the tree at root: it sends every entry of
+// an initial scan as EntryAdded, then TreeReady, then the entries added,
+// changed and removed as later scans find them. libsmb2 has no change
+// notification, so updates come from rescanning every Poll; a change is
+// reported within one period. The channel is closed once ctx is done.
+func (s *Smb) WatchTree(ctx context.Context, root string, opts *WatchOptions) <-chan ChangeEvent {
+	o := WatchOptions{Poll: 30 * time.Second}
+	if opts != nil {
+		o = *opts
+		if o.Poll <= 0 {
+			o.Poll = 30 * time.Second
+		}
+	}
+	events := make(chan ChangeEvent)
+	go func() {
+		defer close(events)
+		send := func(e ChangeEvent) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		var view map[string]ManifestEntry
+		for {
+			m, err := s.watchScan(root, &o)
+			if err != nil {
+				if !send(ChangeEvent{Kind: ScanFailed, Err: err}) {
+					return
+				}
+			} else {
+				next := make(map[string]ManifestEntry, len(m.Entries))
+				for _, e := range m.Entries {
+					next[e.Path] = e
+					old, ok := view[e.Path]
+					switch {
+					case !ok:
+						if !send(ChangeEvent{Kind: EntryAdded, Entry: e}) {
+							return
+						}
+					case old.Dir != e.Dir || old.Size != e.Size || !old.ModTime.Equal(e.ModTime) || old.Sum != e.Sum:
+						if !send(ChangeEvent{Kind: EntryChanged, Entry: e}) {
+							return
+						}
+					}
+				}
+				var removed []string
+				for path := range view {
+					if _, ok := next[path]; !ok {
+						removed = append(removed, path)
+					}
+				}
+				sort.Strings(removed)
+				for _, path := range removed {
+					if !send(ChangeEvent{Kind: EntryRemoved, Entry: view[path]}) {
+						return
+					}
+				}
+				if view == nil && !send(ChangeEvent{Kind: TreeReady}) {
+					return
+				}
+				view = next
+			}
+			select {
+			case <-time.After(o.Poll):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+func (s *Smb) watchScan(root string, o *WatchOptions) (*Manifest, error) {
+	if o.Index != nil && o.Hash != "" {
+		return s.ManifestIndexed(root, o.Hash, o.Index)
+	}
+	return s.Manifest(root, o.Hash)
+}
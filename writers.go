@@ -0,0 +1,98 @@
+package libsmb2
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// layeredWriter is the writer contentWriter returns when content is
+// compressed or sealed. The layers hold partial chunks until Close, so
+// the session tracks it to flush them at a Barrier and close them on
+// Shutdown.
+type layeredWriter struct {
+	mutex sync.Mutex
+	smb   *Smb
+	file  *smbFile
+	// layers runs from the file up to the writer written to.
+	layers []io.WriteCloser
+	closed bool
+	err    error
+}
+
+func (w *layeredWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, w.smb.wrapError("write", w.file.path, ErrClosed)
+	}
+	return w.layers[len(w.layers)-1].Write(p)
+}
+
+func (w *layeredWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.close()
+}
+
+// close closes the layers, the file with them. Must be called with
+// w.mutex held.
+func (w *layeredWriter) close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	w.err = w.layers[len(w.layers)-1].Close()
+	w.smb.mutex.Lock()
+	delete(w.smb.writers, w)
+	w.smb.mutex.Unlock()
+	return w.err
+}
+
+// flush pushes what every layer buffers down to the file, top first.
+// A compressor that cannot flush fails with ErrNotSupported.
+func (w *layeredWriter) flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	for i := len(w.layers) - 1; i > 0; i-- {
+		if err := w.layers[i].(interface{ Flush() error }).Flush(); err != nil {
+			return w.smb.wrapError("flush", w.file.path, err)
+		}
+	}
+	return nil
+}
+
+// writersOpen returns the layered writers still open on the session.
+func (s *Smb) writersOpen() []*layeredWriter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var open []*layeredWriter
+	for w := range s.writers {
+		open = append(open, w)
+	}
+	return open
+}
+
+// closeWriters closes the layered writers of a draining session, writing
+// past the drain. One still busy with a Write is skipped; its file is
+// cut short when the handles are released.
+func (s *Smb) closeWriters() error {
+	var errs []error
+	for _, w := range s.writersOpen() {
+		if !w.mutex.TryLock() {
+			errs = append(errs, s.wrapError("close", w.file.path, errors.New("writer busy at shutdown")))
+			continue
+		}
+		s.mutex.Lock()
+		w.file.final = true
+		s.mutex.Unlock()
+		if err := w.close(); err != nil {
+			errs = append(errs, err)
+		}
+		w.mutex.Unlock()
+	}
+	return errors.Join(errs...)
+}